@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/ozcankasal/zettelo/internal/index"
+)
+
+func runAnalyze(opts *options, args []string) error {
+	fs := newFlagSet("analyze", opts)
+	format := fs.String("format", "text", "output `format`: text or json")
+	out := fs.String("out", "", "write to `file` instead of stdout")
+	hubs := fs.Int("hubs", index.DefaultHubs, "list the `n` most connected notes")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zettelo analyze [--format text|json] [--out file] [--hubs n]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return newUsageError("unknown format %q", *format)
+	}
+	if *hubs < 0 {
+		return newUsageError("--hubs must not be negative")
+	}
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	snapshot, err := buildIndex(ctx, index.NewStore(), config, opts)
+	if err != nil {
+		return err
+	}
+	analysis := index.Analyze(snapshot.Files, snapshot.Tags, snapshot.Links(), *hubs)
+
+	err = writeOutput(*out, func(w io.Writer) error {
+		if *format == "json" {
+			return json.NewEncoder(w).Encode(analysis)
+		}
+		return writeAnalysis(w, analysis)
+	})
+	if err != nil {
+		return err
+	}
+	return reportScanErrors(snapshot)
+}
+
+// writeAnalysis prints one section per finding, with one note per line.
+func writeAnalysis(w io.Writer, analysis *index.Analysis) error {
+	var sb strings.Builder
+	section := func(title, hint string, paths []string) {
+		fmt.Fprintf(&sb, "%s (%d): %s\n", title, len(paths), hint)
+		for _, path := range paths {
+			fmt.Fprintf(&sb, "  %s\n", path)
+		}
+		sb.WriteString("\n")
+	}
+	section("Orphans", "no other note links to them", analysis.Orphans)
+	section("Dead ends", "they link to no other note", analysis.DeadEnds)
+	section("Untagged", "they have no tags", analysis.Untagged)
+
+	fmt.Fprintf(&sb, "Hubs (%d): linked with the most other notes\n", len(analysis.Hubs))
+	for _, hub := range analysis.Hubs {
+		fmt.Fprintf(&sb, "  %s\t%d (%d in, %d out)\n", hub.Path, hub.Degree, hub.Incoming, hub.Outgoing)
+	}
+	sb.WriteString("\n")
+
+	fmt.Fprintf(&sb, "Components (%d): notes connected by links\n", len(analysis.Components))
+	for i, component := range analysis.Components {
+		if len(component) == 1 {
+			fmt.Fprintf(&sb, "  and %d notes without links\n", len(analysis.Components)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "  %d notes: %s\n", len(component), strings.Join(component, ", "))
+	}
+	sb.WriteString("\n")
+
+	section("Bridges", "removing one splits its component", analysis.Bridges)
+	_, err := io.WriteString(w, strings.TrimSuffix(sb.String(), "\n"))
+	return err
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/index"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func runExport(opts *options, args []string) error {
+	if sub, rest := splitSubcommand(args, ""); sub == "graph" {
+		return runExportGraph(opts, rest)
+	}
+
+	fs := newFlagSet("export", opts)
+	format := fs.String("format", "json", "output `format`: json or csv")
+	out := fs.String("out", "", "write to `file` instead of stdout")
+	var where stringList
+	fs.Var(&where, "where", "only export values whose attributes match the `filter`, such as due<2026-12-01; may be repeated")
+	sortKey := fs.String("sort", "", "sort the values of every tag by the attribute `key`")
+	tag := fs.String("tag", "", "only export the `tag`")
+	descendants := fs.Bool("descendants", false, "with --tag, also export the tags nested below it")
+	facets := fs.String("facets", "", "only export the notes matching the `filter`, such as \"type=concept, project=maths-book-writing\"")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zettelo export [--format json|csv] [--out file] [--tag tag [--descendants]] [--facets filter] [--where filter]... [--sort key]")
+		fmt.Fprintln(os.Stderr, "       zettelo export graph [--format dot|graphml|gexf|json] [--out file] [--nodes kinds] [--edges kinds] [--facets filter]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "csv" {
+		return newUsageError("unknown format %q", *format)
+	}
+	var filters []utils.AttributeFilter
+	for _, expr := range where {
+		filter, err := utils.ParseAttributeFilter(expr)
+		if err != nil {
+			return usageError{msg: err.Error()}
+		}
+		filters = append(filters, filter)
+	}
+	facetFilter, err := index.ParseFacetFilter(*facets)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	snapshot, err := buildIndex(ctx, index.NewStore(), config, opts)
+	if err != nil {
+		return err
+	}
+	tagList := snapshot.Tags
+	if *tag != "" {
+		tagList = index.SelectTags(tagList, *tag, *descendants)
+	}
+	tagList = index.FilterFacets(tagList, snapshot.Files, facetFilter)
+	tagList = selectValues(tagList, filters, *sortKey)
+
+	err = writeOutput(*out, func(w io.Writer) error {
+		if *format == "csv" {
+			return writeCSV(w, tagList)
+		}
+		b, err := utils.WriteJSON(tagList)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return reportScanErrors(snapshot)
+}
+
+// runExportGraph exports notes, tags and their relations as a graph.
+func runExportGraph(opts *options, args []string) error {
+	fs := newFlagSet("export graph", opts)
+	format := fs.String("format", "json", "output `format`: "+strings.Join(index.GraphFormats, ", "))
+	out := fs.String("out", "", "write to `file` instead of stdout")
+	nodes := fs.String("nodes", index.NodeTag, "comma-separated `kinds` of nodes besides notes: tag, project, type")
+	edges := fs.String("edges", "", "comma-separated `kinds` of edges: "+strings.Join(index.EdgeKinds, ", ")+" (default all)")
+	facets := fs.String("facets", "", "only export the notes matching the `filter`, such as \"type=concept, project=maths-book-writing\"")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zettelo export graph [--format dot|graphml|gexf|json] [--out file] [--nodes kinds] [--edges kinds] [--facets filter]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	known := false
+	for _, f := range index.GraphFormats {
+		known = known || f == *format
+	}
+	if !known {
+		return newUsageError("unknown format %q", *format)
+	}
+	graphOpts, err := index.ParseGraphOptions(*nodes, *edges)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+	facetFilter, err := index.ParseFacetFilter(*facets)
+	if err != nil {
+		return usageError{msg: err.Error()}
+	}
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	snapshot, err := buildIndex(ctx, index.NewStore(), config, opts)
+	if err != nil {
+		return err
+	}
+	files := make(map[string]internal.Note, len(snapshot.Files))
+	for path, note := range snapshot.Files {
+		if facetFilter.Match(note) {
+			files[path] = note
+		}
+	}
+	graph := index.BuildGraph(files, snapshot.Links(), graphOpts)
+
+	err = writeOutput(*out, func(w io.Writer) error {
+		return index.WriteGraph(w, graph, *format)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d nodes and %d edges.\n", len(graph.Nodes), len(graph.Edges))
+	return reportScanErrors(snapshot)
+}
+
+// selectValues returns the values matching all filters, leaving out tags without
+// matching values. With a sort key, the values of every tag are ordered by that
+// attribute; values without it come last.
+func selectValues(tagList internal.TagList, filters []utils.AttributeFilter, sortKey string) internal.TagList {
+	if len(filters) == 0 && sortKey == "" {
+		return tagList
+	}
+
+	var result internal.TagList
+	for _, tag := range tagList {
+		var values []internal.ResultValue
+	values:
+		for _, value := range tag.Values {
+			for _, filter := range filters {
+				if !filter.Match(value) {
+					continue values
+				}
+			}
+			values = append(values, value)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if sortKey != "" {
+			sort.SliceStable(values, func(i, j int) bool {
+				a, okA := utils.FindAttribute(values[i], sortKey)
+				b, okB := utils.FindAttribute(values[j], sortKey)
+				if !okA || !okB {
+					return okA && !okB
+				}
+				return utils.CompareAttributes(a, b) < 0
+			})
+		}
+		result = append(result, internal.TaggedLine{Tag: tag.Tag, Values: values})
+	}
+	return result
+}
+
+// writeOutput calls write with stdout, or with the named file when path is set.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeCSV writes one record per tagged value with the tag, its text and its location.
+// The location column has the form path:line:column understood by most editors.
+func writeCSV(w io.Writer, tagList internal.TagList) error {
+	cw := csv.NewWriter(w)
+	header := []string{"tag", "file_path", "line", "line_number", "column", "location", "heading", "note_id", "body", "attributes", "origin"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, tag := range tagList {
+		for _, value := range tag.Values {
+			record := []string{tag.Tag, value.FilePath, value.Line, "", "", value.FilePath, value.Heading, value.NoteID, strings.Join(value.Body, "\n"), formatAttributes(value.Attributes), value.Origin}
+			if value.LineNumber > 0 {
+				record[3] = strconv.Itoa(value.LineNumber)
+				record[4] = strconv.Itoa(value.Column)
+				record[5] = fmt.Sprintf("%s:%d:%d", value.FilePath, value.LineNumber, value.Column)
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatAttributes writes attributes as key=value pairs separated by semicolons.
+func formatAttributes(attrs []internal.Attribute) string {
+	pairs := make([]string, len(attrs))
+	for i, attr := range attrs {
+		pairs[i] = attr.Key + "=" + attr.Value
+	}
+	return strings.Join(pairs, "; ")
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/ids"
+	"github.com/ozcankasal/zettelo/internal/scanner"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func runIDs(opts *options, args []string) error {
+	fs := newFlagSet("ids", opts)
+	dryRun := fs.Bool("dry-run", false, "assign: print the changes without writing any file")
+	parent := fs.String("parent", "", "next: branch off the note with this `id` (folgezettel only)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zettelo ids [list]")
+		fmt.Fprintln(os.Stderr, "       zettelo ids assign [--dry-run]")
+		fmt.Fprintln(os.Stderr, "       zettelo ids check")
+		fmt.Fprintln(os.Stderr, "       zettelo ids next [--parent id]")
+		fs.PrintDefaults()
+	}
+	sub, args := splitSubcommand(args, "list")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+	scheme := utils.IDScheme(*config)
+	if *parent != "" && (sub != "next" || scheme.Name() != ids.Folgezettel) {
+		return newUsageError("--parent only works with ids next and the %s scheme", ids.Folgezettel)
+	}
+
+	// Like scan, work on the notes that could be read and report the others at the end.
+	files, scanErrors := scanner.ListFiles(config.App.Folders)
+
+	switch sub {
+	case "list":
+		for _, file := range files {
+			fmt.Printf("%s\t%s\n", utils.ReadID(file, *config), file)
+		}
+	case "assign":
+		var skipped []internal.ScanError
+		skipped, err = assignIDs(files, *config, *dryRun)
+		scanErrors = append(scanErrors, skipped...)
+	case "check":
+		err = checkIDs(files, *config)
+	case "next":
+		taken := readIDs(files, *config)
+		isTaken := func(id string) bool { return taken[id] != "" }
+		id := scheme.New(isTaken)
+		if *parent != "" {
+			if id, err = (ids.FolgezettelScheme{}).Child(*parent, isTaken); err != nil {
+				return usageError{msg: err.Error()}
+			}
+		}
+		fmt.Println(id)
+	default:
+		return newUsageError("unknown ids command %q", sub)
+	}
+
+	if scanErr := reportErrors(scanErrors); err == nil {
+		err = scanErr
+	}
+	return err
+}
+
+// readIDs maps the ID of every note to its file.
+func readIDs(files []string, config internal.Config) map[string]string {
+	taken := make(map[string]string, len(files))
+	for _, file := range files {
+		if id := utils.ReadID(file, config); id != "" {
+			taken[id] = file
+		}
+	}
+	return taken
+}
+
+type pendingWrite struct {
+	path    string
+	mode    os.FileMode
+	content []byte
+	// rename is the new path of the file, if it is renamed.
+	rename string
+}
+
+// assignIDs adds an ID of the configured scheme to every note without one and, in
+// filename prefix mode, puts the ID in front of the names of the notes lacking it. The
+// changes are printed before any file is written. Notes that cannot be read or whose
+// header cannot be decoded are left alone and returned. No file is written when a note
+// would be renamed onto an existing file or onto the new name of another note; a note
+// that cannot be written is reported and the others are still written.
+func assignIDs(files []string, config internal.Config, dryRun bool) ([]internal.ScanError, error) {
+	scheme := utils.IDScheme(config)
+	taken := readIDs(files, config)
+	isTaken := func(id string) bool { return taken[id] != "" }
+
+	var writes []pendingWrite
+	var skipped []internal.ScanError
+	// renamed maps the new name of every queued rename to the note renamed to it.
+	renamed := make(map[string]string)
+	conflicts := 0
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			skipped = append(skipped, scanner.NewError(file, err))
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			skipped = append(skipped, scanner.NewError(file, err))
+			continue
+		}
+
+		id := utils.ReadID(file, config)
+		if id == "" {
+			id = scheme.New(isTaken)
+		}
+		newContent, changed, err := utils.AssignID(content, id)
+		if err != nil {
+			scanErr := scanner.NewError(file, err)
+			scanErr.Kind = scanner.ErrorHeader
+			skipped = append(skipped, scanErr)
+			continue
+		}
+		taken[id] = file
+
+		rename := ""
+		if config.App.IDFilenamePrefix && ids.FilenamePrefix(scheme, file) == "" {
+			if !scheme.Valid(id) {
+				fmt.Fprintf(os.Stderr, "%s: not renamed, %q is not a valid %s ID\n", file, id, scheme.Name())
+			} else {
+				rename = ids.WithFilenamePrefix(file, id)
+				if _, err := os.Stat(rename); err == nil {
+					fmt.Fprintf(os.Stderr, "%s: cannot be renamed, %s exists\n", file, rename)
+					conflicts++
+				} else if other, ok := renamed[rename]; ok {
+					fmt.Fprintf(os.Stderr, "%s: cannot be renamed, %s is also renamed to %s\n", file, other, rename)
+					conflicts++
+				}
+				renamed[rename] = file
+			}
+		}
+		if !changed && rename == "" {
+			continue
+		}
+
+		if rename != "" {
+			fmt.Printf("rename %s => %s\n", file, rename)
+		}
+		fmt.Print(utils.UnifiedDiff(file, file, content, newContent))
+		writes = append(writes, pendingWrite{path: file, mode: info.Mode().Perm(), content: newContent, rename: rename})
+	}
+
+	need := "an ID"
+	if config.App.IDFilenamePrefix {
+		need = "an ID or a file name prefix"
+	}
+	if conflicts > 0 {
+		return skipped, fmt.Errorf("%d notes cannot be renamed; no files were written", conflicts)
+	}
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "%d notes need %s; no files were written.\n", len(writes), need)
+		return skipped, nil
+	}
+
+	// A failed write does not stop the others; every failure is reported.
+	failed := 0
+	for _, w := range writes {
+		if err := utils.WriteFile(w.path, w.content, w.mode); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: not changed: %v\n", w.path, err)
+			failed++
+			continue
+		}
+		if w.rename != "" {
+			if err := os.Rename(w.path, w.rename); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: ID added but not renamed: %v\n", w.path, err)
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return skipped, fmt.Errorf("%d of %d notes could not be changed", failed, len(writes))
+	}
+	fmt.Fprintf(os.Stderr, "Gave %s to %d notes.\n", need, len(writes))
+	return skipped, nil
+}
+
+// checkIDs reports the notes whose ID does not match the configured scheme, IDs used by
+// several notes and, in filename prefix mode, file names starting with another ID than
+// the front matter.
+func checkIDs(files []string, config internal.Config) error {
+	scheme := utils.IDScheme(config)
+	frontMatter := config
+	frontMatter.App.IDFilenamePrefix = false
+
+	seen := make(map[string]string)
+	problems := 0
+	report := func(file, format string, a ...interface{}) {
+		fmt.Printf("%s: %s\n", file, fmt.Sprintf(format, a...))
+		problems++
+	}
+	for _, file := range files {
+		id := utils.ReadID(file, config)
+		if id == "" {
+			continue
+		}
+		if !scheme.Valid(id) {
+			report(file, "%q is not a valid %s ID", id, scheme.Name())
+		}
+		if other, ok := seen[id]; ok {
+			report(file, "ID %q is also used by %s", id, other)
+		} else {
+			seen[id] = file
+		}
+		if config.App.IDFilenamePrefix {
+			prefix := ids.FilenamePrefix(scheme, file)
+			if fmID := utils.ReadID(file, frontMatter); prefix != "" && fmID != "" && prefix != fmID {
+				report(file, "file name starts with %q but the ID is %q", prefix, fmID)
+			}
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("found %d problems with note IDs", problems)
+	}
+	fmt.Fprintf(os.Stderr, "Checked %d notes; every ID matches the %s scheme.\n", len(files), scheme.Name())
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// listNotes returns the names of the files of a folder.
+func listNotes(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestIDsAssignRenamesWithPrefix(t *testing.T) {
+	vault, config := testVault(t, map[string]string{
+		"Limits.md": "---\nid: \"202610181230\"\n---\n# Limits\n",
+	}, "  id_scheme: timestamp\n  id_filename_prefix: true\n")
+
+	code, out, errOut := runZettelo(t, "--config", config, "ids", "assign")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, errOut)
+	}
+	if !strings.Contains(out, "rename "+filepath.Join(vault, "Limits.md")+" => "+filepath.Join(vault, "202610181230 Limits.md")) {
+		t.Errorf("Expected the rename to be printed, got %q", out)
+	}
+	if names := listNotes(t, vault); !reflect.DeepEqual(names, []string{"202610181230 Limits.md"}) {
+		t.Errorf("Expected the note to be renamed, got %v", names)
+	}
+}
+
+func TestIDsAssignRenameCollision(t *testing.T) {
+	// The vault is listed twice, so its note is queued for the same rename twice.
+	vault, config := testVault(t, map[string]string{
+		"Limits.md": "---\nid: \"202610181230\"\n---\n# Limits\n",
+	}, "  id_scheme: timestamp\n  id_filename_prefix: true\n")
+
+	code, _, errOut := runZettelo(t, "--config", config, "--vault", vault, "--vault", vault, "ids", "assign")
+	if code != exitError {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitError, code, errOut)
+	}
+	if !strings.Contains(errOut, "is also renamed to "+filepath.Join(vault, "202610181230 Limits.md")) {
+		t.Errorf("Expected the collision to be reported, got %q", errOut)
+	}
+	if names := listNotes(t, vault); !reflect.DeepEqual(names, []string{"Limits.md"}) {
+		t.Errorf("Expected no file to be renamed, got %v", names)
+	}
+}
+
+func TestIDsAssignDryRun(t *testing.T) {
+	vault, config := testVault(t, map[string]string{"a.md": "# A\n"}, "")
+
+	code, out, errOut := runZettelo(t, "--config", config, "ids", "assign", "--dry-run")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, errOut)
+	}
+	if !strings.Contains(out, "+id: ") {
+		t.Errorf("Expected the diff to be printed, got %q", out)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(vault, "a.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "# A\n" {
+		t.Errorf("Expected the note to be left alone, got %q", content)
+	}
+}
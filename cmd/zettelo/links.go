@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/index"
+)
+
+func runLinks(opts *options, args []string) error {
+	fs := newFlagSet("links", opts)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zettelo links [check]\n       zettelo links backlinks note...")
+		fs.PrintDefaults()
+	}
+	sub, args := splitSubcommand(args, "check")
+	maxArgs := 0
+	if sub == "backlinks" {
+		maxArgs = len(args)
+	}
+	if err := parseFlags(fs, args, maxArgs); err != nil {
+		return err
+	}
+
+	switch sub {
+	case "check":
+	case "backlinks":
+		if fs.NArg() == 0 {
+			return newUsageError("links backlinks needs at least one note")
+		}
+	default:
+		return newUsageError("unknown links command %q", sub)
+	}
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	snapshot, err := buildIndex(ctx, index.NewStore(), config, opts)
+	if err != nil {
+		return err
+	}
+
+	if sub == "backlinks" {
+		if err := printBacklinks(snapshot, fs.Args()); err != nil {
+			return err
+		}
+		return reportScanErrors(snapshot)
+	}
+
+	problems := snapshot.Links().Problems()
+	for _, link := range problems {
+		if link.Status == index.LinkAmbiguous {
+			fmt.Printf("%s:%d:%d: ambiguous link %s matches %s\n", link.Source, link.LineNumber, link.Column, formatLink(link.Link), strings.Join(link.Candidates, ", "))
+			continue
+		}
+		fmt.Printf("%s:%d:%d: unresolved link %s\n", link.Source, link.LineNumber, link.Column, formatLink(link.Link))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d broken links", len(problems))
+	}
+	fmt.Fprintf(os.Stderr, "Checked the links of %d notes; every link resolves.\n", len(snapshot.Files))
+	return reportScanErrors(snapshot)
+}
+
+// printBacklinks prints the notes linking to each note. A note is named by its path or
+// like the target of a wiki link.
+func printBacklinks(snapshot *index.Snapshot, notes []string) error {
+	resolver := index.NewResolver(snapshot.Files)
+	for _, name := range notes {
+		target := findNote(snapshot, resolver, name)
+		if target.Status == index.LinkAmbiguous {
+			return fmt.Errorf("%q matches several notes: %s", name, strings.Join(target.Candidates, ", "))
+		}
+		if target.Status != index.LinkResolved {
+			return fmt.Errorf("no note matches %q", name)
+		}
+		if len(notes) > 1 {
+			fmt.Printf("%s:\n", target.Path)
+		}
+		for _, link := range snapshot.Links().Backlinks[target.Path] {
+			fmt.Printf("%s:%d:%d: %s\n", link.Source, link.LineNumber, link.Column, formatLink(link.Link))
+		}
+	}
+	return nil
+}
+
+// findNote resolves a note given on the command line.
+func findNote(snapshot *index.Snapshot, resolver *index.Resolver, name string) index.ResolvedLink {
+	if abs, err := filepath.Abs(name); err == nil {
+		for _, path := range []string{name, abs} {
+			if _, ok := snapshot.Files[path]; ok {
+				return index.ResolvedLink{Status: index.LinkResolved, Path: path}
+			}
+		}
+	}
+	return resolver.Resolve("", internal.Link{Kind: internal.LinkWiki, Target: name})
+}
+
+// formatLink writes a link the way it is written in a note.
+func formatLink(link internal.Link) string {
+	target := link.Target
+	if link.Heading != "" {
+		target += "#" + link.Heading
+	}
+	if link.Kind == internal.LinkMarkdown {
+		return "(" + target + ")"
+	}
+	if link.Alias != "" {
+		target += "|" + link.Alias
+	}
+	return "[[" + target + "]]"
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/cache"
+	"github.com/ozcankasal/zettelo/internal/index"
+	"github.com/ozcankasal/zettelo/internal/scanner"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func runScan(opts *options, args []string) error {
+	fs := newFlagSet("scan", opts)
+	out := fs.String("out", "", "write the tag index to `file` instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zettelo scan [--out file]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	snapshot, err := buildIndex(ctx, index.NewStore(), config, opts)
+	if err != nil {
+		return err
+	}
+
+	b, err := utils.WriteJSON(snapshot.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to write JSON output: %w", err)
+	}
+	err = writeOutput(*out, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "%s\n", b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Scanned %d files in %d folders, found %d tags.\n", len(snapshot.Files), len(config.App.Folders), len(snapshot.Tags))
+	return reportScanErrors(snapshot)
+}
+
+// buildIndex scans every folder of the configuration and publishes the result to the store.
+// Files that cannot be read are recorded as errors in the snapshot. Unless disabled, parse
+// results are cached on disk so that only changed notes are parsed on the next run. The
+// scan stops with an error when ctx is cancelled, as on Ctrl-C, and nothing is published.
+func buildIndex(ctx context.Context, store *index.Store, config *internal.Config, opts *options) (*index.Snapshot, error) {
+	c := openCache(config, opts)
+	result, err := scanner.Scan(ctx, config.App.Folders, *config, scanner.Options{Cache: c})
+	if err != nil {
+		return nil, fmt.Errorf("scan interrupted: %w", err)
+	}
+	if c != nil {
+		if err := c.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save the index cache: %v\n", err)
+		}
+	}
+	return store.Replace(result.Files, result.Errors), nil
+}
+
+// openCache loads the index cache configured by app.cache, or the default cache of the
+// vault. It returns nil when caching is disabled with --no-cache.
+func openCache(config *internal.Config, opts *options) *cache.Cache {
+	if opts.noCache {
+		return nil
+	}
+	path := config.App.Cache
+	if path == "" {
+		defaultPath, err := cache.DefaultPath(config.App.Folders)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to locate the index cache: %v\n", err)
+			return nil
+		}
+		path = defaultPath
+	}
+	return cache.Load(path, cache.Key(*config))
+}
+
+// reportScanErrors prints a summary of the scan errors to stderr and returns
+// errPartialScan if there were any.
+func reportScanErrors(snapshot *index.Snapshot) error {
+	return reportErrors(snapshot.Errors)
+}
+
+// reportErrors prints the files that could not be scanned to stderr and returns
+// errPartialScan if there were any.
+func reportErrors(scanErrors []internal.ScanError) error {
+	if len(scanErrors) == 0 {
+		return nil
+	}
+	for _, scanErr := range scanErrors {
+		fmt.Fprintf(os.Stderr, "%s: %s: %s\n", scanErr.Kind, scanErr.Path, scanErr.Message)
+	}
+	return fmt.Errorf("%d files could not be scanned: %w", len(scanErrors), errPartialScan)
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/hub"
+	"github.com/ozcankasal/zettelo/internal/index"
+	"github.com/ozcankasal/zettelo/internal/scanner"
+	"github.com/ozcankasal/zettelo/internal/utils"
+	"github.com/ozcankasal/zettelo/internal/watcher"
+)
+
+func runServe(opts *options, args []string) error {
+	fs := newFlagSet("serve", opts)
+	assignIDs := fs.Bool("assign-ids", false, "add an id to the header of notes that are created or changed without one")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zettelo serve [--assign-ids]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+	if *assignIDs {
+		config.App.AssignIDs = true
+	}
+	return serve(config, opts)
+}
+
+// server keeps the index of a vault current while serving it over HTTP.
+type server struct {
+	config  internal.Config
+	store   *index.Store
+	watcher *watcher.Watcher
+	clients *hub.Hub
+	// versions only receive the version of every index, see versionMessage.
+	versions *hub.Hub
+}
+
+func serve(config *internal.Config, opts *options) error {
+	store := index.NewStore()
+	// Ctrl-C stops the initial scan cleanly; afterwards it ends the server as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	snapshot, err := buildIndex(ctx, store, config, opts)
+	stop()
+	if err != nil {
+		return err
+	}
+	if len(snapshot.Errors) > 0 {
+		log.Printf("%d files could not be scanned, see /api/errors", len(snapshot.Errors))
+	}
+
+	// Watch the folders, including directories created later on.
+	w, err := watcher.New(config.App.Folders, config.App.Debounce)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	s := &server{
+		config:  *config,
+		store:   store,
+		watcher: w,
+		// Every connected client receives the current index and then every update.
+		clients:  hub.New(func() []byte { return store.Snapshot().JSON() }),
+		versions: hub.New(func() []byte { return versionMessage(store.Snapshot()) }),
+	}
+	go s.watch()
+
+	http.Handle("/", http.FileServer(http.Dir("./static")))
+	http.Handle("/hashtags", s.clients)
+	http.Handle("/versions", s.versions)
+	http.Handle("/api/index", indexHandler(store))
+	http.Handle("/api/errors", errorsHandler(store))
+	http.Handle("/api/tags", tagsHandler(store))
+	http.Handle("/api/notes", notesHandler(store))
+	http.Handle("/api/facets", facetsHandler(store))
+	http.Handle("/api/links", linksHandler(store))
+	http.Handle("/api/graph", graphHandler(store))
+	http.Handle("/api/analysis", analysisHandler(store))
+	http.Handle("/api/settings", settingsHandler(config.Web))
+
+	url := fmt.Sprintf("%s:%d", config.Web.Host, config.Web.Port)
+	fmt.Printf("Server is listening on %s. Click %s to open in browser.\n", url, url)
+
+	return http.ListenAndServe(url, nil)
+}
+
+// watch applies watcher events to the index and broadcasts every new snapshot.
+func (s *server) watch() {
+	for {
+		select {
+		case event, ok := <-s.watcher.Events():
+			if !ok {
+				return
+			}
+			previous := s.store.Snapshot()
+			if snapshot := s.applyEvent(event); snapshot != previous {
+				s.clients.Broadcast(snapshot.JSON())
+				s.versions.Broadcast(versionMessage(snapshot))
+			}
+		case err, ok := <-s.watcher.Errors():
+			if !ok {
+				return
+			}
+			log.Println("error:", err)
+		}
+	}
+}
+
+// versionMessage is the message of the /versions websocket, for pages that only need to
+// know when the index changes.
+func versionMessage(snapshot *index.Snapshot) []byte {
+	return []byte(fmt.Sprintf(`{"version":%d}`, snapshot.Version))
+}
+
+// applyEvent updates the index for one watcher event and returns the new snapshot.
+func (s *server) applyEvent(event watcher.Event) *index.Snapshot {
+	if event.Op == watcher.Remove {
+		return s.store.RemoveTree(event.Path)
+	}
+
+	if s.config.App.AssignIDs {
+		if err := s.assignID(event.Path); err != nil {
+			log.Println("error:", err)
+		}
+	}
+
+	// Only the file named in the event is parsed again.
+	note, err := scanner.ParseFile(event.Path, s.config)
+	if err != nil {
+		scanErr := scanner.NewError(event.Path, err)
+		if scanErr.Kind == scanner.ErrorNotFound {
+			// The file was removed again; its remove event follows.
+			return s.store.RemoveFile(event.Path)
+		}
+		log.Println("error:", err)
+		return s.store.FailFile(event.Path, scanErr)
+	}
+	return s.store.UpdateFile(event.Path, note)
+}
+
+// assignID adds an ID of the configured scheme to the header of the note if it has none;
+// notes are never renamed. The write is announced to the watcher so it is not reported
+// back as an external change.
+func (s *server) assignID(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	id := utils.ReadID(path, s.config)
+	if id == "" {
+		taken := make(map[string]bool)
+		for _, note := range s.store.Snapshot().Files {
+			taken[note.ID] = true
+		}
+		id = utils.IDScheme(s.config).New(func(id string) bool { return taken[id] })
+	}
+	newContent, changed, err := utils.AssignID(content, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if !changed {
+		return nil
+	}
+	s.watcher.IgnoreWrite(path, newContent)
+	return utils.WriteFile(path, newContent, info.Mode().Perm())
+}
+
+// indexHandler serves the current snapshot as JSON. The version is sent as the
+// X-Index-Version header and as ETag, so clients can check whether they are current.
+func indexHandler(store *index.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot := store.Snapshot()
+		version := strconv.FormatUint(snapshot.Version, 10)
+		etag := `"` + version + `"`
+
+		w.Header().Set("X-Index-Version", version)
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(snapshot.JSON())
+	})
+}
+
+// errorsHandler serves the scan errors of the current snapshot as JSON.
+func errorsHandler(store *index.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot := store.Snapshot()
+		w.Header().Set("X-Index-Version", strconv.FormatUint(snapshot.Version, 10))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Version uint64               `json:"version"`
+			Errors  []internal.ScanError `json:"errors"`
+		}{snapshot.Version, snapshot.Errors})
+	})
+}
+
+// notesHandler serves the front matter fields of every note as JSON, sorted by path.
+func notesHandler(store *index.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot := store.Snapshot()
+		w.Header().Set("X-Index-Version", strconv.FormatUint(snapshot.Version, 10))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Version uint64          `json:"version"`
+			Notes   []internal.Note `json:"notes"`
+		}{snapshot.Version, snapshot.Notes()})
+	})
+}
+
+// facetsHandler serves the number of notes by type and project as JSON.
+func facetsHandler(store *index.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot := store.Snapshot()
+		w.Header().Set("X-Index-Version", strconv.FormatUint(snapshot.Version, 10))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Version uint64        `json:"version"`
+			Facets  []index.Facet `json:"facets"`
+		}{snapshot.Version, snapshot.Facets})
+	})
+}
+
+// linksHandler serves the unresolved and ambiguous links as JSON. With the path query
+// parameter it serves the links and backlinks of that note instead.
+func linksHandler(store *index.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot := store.Snapshot()
+		w.Header().Set("X-Index-Version", strconv.FormatUint(snapshot.Version, 10))
+		w.Header().Set("Content-Type", "application/json")
+
+		path := r.URL.Query().Get("path")
+		if path == "" {
+			json.NewEncoder(w).Encode(struct {
+				Version  uint64               `json:"version"`
+				Problems []index.ResolvedLink `json:"problems"`
+			}{snapshot.Version, snapshot.Links().Problems()})
+			return
+		}
+		if _, ok := snapshot.Files[path]; !ok {
+			http.Error(w, "unknown note", http.StatusNotFound)
+			return
+		}
+		graph := snapshot.Links()
+		links, backlinks := graph.Links[path], graph.Backlinks[path]
+		if links == nil {
+			links = []index.ResolvedLink{}
+		}
+		if backlinks == nil {
+			backlinks = []index.ResolvedLink{}
+		}
+		json.NewEncoder(w).Encode(struct {
+			Version   uint64               `json:"version"`
+			Links     []index.ResolvedLink `json:"links"`
+			Backlinks []index.ResolvedLink `json:"backlinks"`
+		}{snapshot.Version, links, backlinks})
+	})
+}
+
+// graphEdges are the edge kinds of /api/graph unless the edges parameter says otherwise.
+// Shared project edges join every pair of notes of a project, which is too many to draw
+// for a large project.
+var graphEdges = func() string {
+	var kinds []string
+	for _, kind := range index.EdgeKinds {
+		if kind != index.EdgeSharedProject {
+			kinds = append(kinds, kind)
+		}
+	}
+	return strings.Join(kinds, ",")
+}()
+
+// maxGraphNodes is the number of nodes /api/graph serves without a filter. The web UI
+// lays out every node it receives on the client.
+const maxGraphNodes = 1000
+
+// maxCachedGraphs bounds the number of graphs kept for the current snapshot.
+const maxCachedGraphs = 32
+
+// graphCache keeps the encoded graphs of the current snapshot by request, so that the
+// open graph tabs reloading after a change do not build the same graph again.
+type graphCache struct {
+	mu      sync.Mutex
+	version uint64
+	graphs  map[string][]byte
+}
+
+// get returns the graph cached for the key at the version, if any.
+func (c *graphCache) get(version uint64, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != version {
+		return nil, false
+	}
+	graph, ok := c.graphs[key]
+	return graph, ok
+}
+
+// put caches a graph, dropping the graphs of older snapshots.
+func (c *graphCache) put(version uint64, key string, graph []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version < c.version {
+		return
+	}
+	if c.version != version || len(c.graphs) >= maxCachedGraphs {
+		c.version = version
+		c.graphs = make(map[string][]byte)
+	}
+	c.graphs[key] = graph
+}
+
+// graphHandler serves the graph of the notes as JSON, see index.BuildGraph. The nodes and
+// edges query parameters select kinds like export graph does, except that shared project
+// edges are left out unless asked for; tag and project keep the matching notes, and note
+// keeps the nodes within depth edges (default 1) of a note, given by path or like a wiki
+// link target. Without any of these filters only the maxGraphNodes most connected nodes
+// are served. Graphs are cached until the index changes.
+func graphHandler(store *index.Store) http.Handler {
+	cache := &graphCache{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot := store.Snapshot()
+		query := r.URL.Query()
+
+		nodes := index.NodeTag
+		if query.Has("nodes") {
+			nodes = query.Get("nodes")
+		}
+		edges := graphEdges
+		if query.Has("edges") {
+			edges = query.Get("edges")
+		}
+		opts, err := index.ParseGraphOptions(nodes, edges)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter := index.GraphFilter{Tag: query.Get("tag"), Project: query.Get("project"), Depth: 1}
+		if depth := query.Get("depth"); depth != "" {
+			if filter.Depth, err = strconv.Atoi(depth); err != nil || filter.Depth < 0 {
+				http.Error(w, "depth must be a number of edges", http.StatusBadRequest)
+				return
+			}
+		}
+		if note := query.Get("note"); note != "" {
+			focus := index.ResolvedLink{Status: index.LinkResolved, Path: note}
+			if _, ok := snapshot.Files[note]; !ok {
+				focus = index.NewResolver(snapshot.Files).Resolve("", internal.Link{Kind: internal.LinkWiki, Target: note})
+			}
+			if focus.Status != index.LinkResolved {
+				http.Error(w, "unknown note", http.StatusNotFound)
+				return
+			}
+			filter.Focus = focus.Path
+		}
+
+		key := fmt.Sprintf("%q %q %+v", opts.Nodes, opts.Edges, filter)
+		body, ok := cache.get(snapshot.Version, key)
+		if !ok {
+			graph := index.FilterGraph(snapshot.Files, snapshot.Links(), opts, filter)
+			total, truncated := len(graph.Nodes), false
+			if filter.Tag == "" && filter.Project == "" && filter.Focus == "" {
+				graph, truncated = index.LimitGraph(graph, maxGraphNodes)
+			}
+			body, err = json.Marshal(struct {
+				Version   uint64            `json:"version"`
+				Focus     string            `json:"focus,omitempty"`
+				Total     int               `json:"total"`
+				Truncated bool              `json:"truncated"`
+				Nodes     []index.GraphNode `json:"nodes"`
+				Links     []index.GraphEdge `json:"links"`
+			}{snapshot.Version, filter.Focus, total, truncated, graph.Nodes, graph.Edges})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			cache.put(snapshot.Version, key, body)
+		}
+		w.Header().Set("X-Index-Version", strconv.FormatUint(snapshot.Version, 10))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// analysisHandler serves the analysis of the notes as JSON, see index.Analyze. The hubs
+// query parameter sets the number of hubs.
+func analysisHandler(store *index.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot := store.Snapshot()
+		hubs := index.DefaultHubs
+		if n := r.URL.Query().Get("hubs"); n != "" {
+			var err error
+			if hubs, err = strconv.Atoi(n); err != nil || hubs < 0 {
+				http.Error(w, "hubs must be a number of notes", http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("X-Index-Version", strconv.FormatUint(snapshot.Version, 10))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Version uint64 `json:"version"`
+			*index.Analysis
+		}{snapshot.Version, index.Analyze(snapshot.Files, snapshot.Tags, snapshot.Links(), hubs)})
+	})
+}
+
+// tagsHandler serves the tag tree as JSON. With the tag query parameter it serves the
+// entries of that tag instead, including its descendants when descendants=true.
+func tagsHandler(store *index.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot := store.Snapshot()
+		w.Header().Set("X-Index-Version", strconv.FormatUint(snapshot.Version, 10))
+		w.Header().Set("Content-Type", "application/json")
+
+		tag := r.URL.Query().Get("tag")
+		if tag == "" {
+			json.NewEncoder(w).Encode(struct {
+				Version uint64           `json:"version"`
+				Tree    []*index.TagNode `json:"tree"`
+			}{snapshot.Version, snapshot.Tree})
+			return
+		}
+		descendants, _ := strconv.ParseBool(r.URL.Query().Get("descendants"))
+		json.NewEncoder(w).Encode(struct {
+			Version uint64           `json:"version"`
+			Tags    internal.TagList `json:"tags"`
+		}{snapshot.Version, index.SelectTags(snapshot.Tags, tag, descendants)})
+	})
+}
+
+// settingsHandler serves the settings the web UI needs as JSON.
+func settingsHandler(web internal.WebConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			EditorURL string `json:"editor_url"`
+		}{web.EditorURL})
+	})
+}
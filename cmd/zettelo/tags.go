@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/index"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func runTags(opts *options, args []string) error {
+	fs := newFlagSet("tags", opts)
+	scope := fs.String("scope", index.ScopeLine, "related, synonyms: count tags used together on one `line`, in one section or in one note")
+	metric := fs.String("metric", index.MetricPMI, "related: score related tags by `pmi` or jaccard")
+	limit := fs.Int("limit", 10, "related: list at most `n` tags for each tag")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: zettelo tags [list|tree]\n       zettelo tags explain tag...")
+		fmt.Fprintln(os.Stderr, "       zettelo tags related [--scope line|section|note] [--metric pmi|jaccard] [--limit n] tag...")
+		fmt.Fprintln(os.Stderr, "       zettelo tags synonyms [--scope line|section|note]")
+		fs.PrintDefaults()
+	}
+	sub, args := splitSubcommand(args, "list")
+	maxArgs := 0
+	if sub == "explain" || sub == "related" {
+		maxArgs = len(args)
+	}
+	if err := parseFlags(fs, args, maxArgs); err != nil {
+		return err
+	}
+
+	switch sub {
+	case "list", "tree", "synonyms":
+	case "explain", "related":
+		if fs.NArg() == 0 {
+			return newUsageError("tags %s needs at least one tag", sub)
+		}
+	default:
+		return newUsageError("unknown tags command %q", sub)
+	}
+
+	config, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	if sub == "explain" {
+		return explainTags(*config, fs.Args())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	snapshot, err := buildIndex(ctx, index.NewStore(), config, opts)
+	if err != nil {
+		return err
+	}
+	tagList := snapshot.Tags
+
+	if sub == "related" || sub == "synonyms" {
+		cooccurrence, err := index.BuildCooccurrence(snapshot.Files, *scope)
+		if err != nil {
+			return usageError{msg: err.Error()}
+		}
+		if sub == "synonyms" {
+			printSynonyms(cooccurrence)
+		} else if err := printRelated(cooccurrence, *config, fs.Args(), *metric, *limit); err != nil {
+			return err
+		}
+		return reportScanErrors(snapshot)
+	}
+
+	if sub == "tree" {
+		printTree(snapshot.Tree, 0)
+		return reportScanErrors(snapshot)
+	}
+	for _, tag := range tagList {
+		fmt.Printf("%s\t%d\n", tag.Tag, len(tag.Values))
+	}
+	return reportScanErrors(snapshot)
+}
+
+// explainTags prints the canonical form of every tag and the rule that produced it.
+func explainTags(config internal.Config, tags []string) error {
+	mapper, err := utils.NewTagMapper(config.App)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		canonical, rule := mapper.Explain(tag)
+		if rule == nil {
+			fmt.Printf("%s -> %s (no rule matched)\n", tag, canonical)
+			continue
+		}
+		fmt.Printf("%s -> %s by %s\n", tag, canonical, rule)
+	}
+	return nil
+}
+
+// printRelated prints the tags used together with each tag, best scoring first, and
+// marks the probable synonyms.
+func printRelated(cooccurrence *index.Cooccurrence, config internal.Config, tags []string, metric string, limit int) error {
+	for i, tag := range tags {
+		// Look up the tag the way it is indexed.
+		if !strings.HasPrefix(tag, "#") {
+			tag = "#" + tag
+		}
+		tag, _ = config.App.Mapper.CanonicalTag(tag)
+		related, err := cooccurrence.Related(tag, metric)
+		if err != nil {
+			return usageError{msg: err.Error()}
+		}
+
+		if len(tags) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", tag)
+		}
+		if cooccurrence.Counts[tag] == 0 {
+			fmt.Fprintf(os.Stderr, "%s is not used\n", tag)
+			continue
+		}
+		if len(related) > limit {
+			related = related[:limit]
+		}
+		for _, r := range related {
+			line := fmt.Sprintf("%s\t%s %.3f\t%d of %s with %s", r.Tag, metric, r.Score, r.Together, plural(r.Count, cooccurrence.Scope), tag)
+			if r.Synonym {
+				line += "\tprobable synonym"
+			}
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
+
+// printSynonyms prints the tags that are mostly used together, followed by the
+// tag_mappings that would merge them.
+func printSynonyms(cooccurrence *index.Cooccurrence) {
+	candidates := cooccurrence.SynonymCandidates()
+	if len(candidates) == 0 {
+		fmt.Fprintf(os.Stderr, "No tags are mostly used together on the same %s.\n", cooccurrence.Scope)
+		return
+	}
+	for _, c := range candidates {
+		fmt.Printf("%s -> %s: together in %s, Jaccard %.3f\n", c.Tag, c.Canonical, plural(c.Together, cooccurrence.Scope), c.Jaccard)
+	}
+	fmt.Println("\nCandidates for app.tag_mappings:")
+	fmt.Println("  tag_mappings:")
+	for _, c := range candidates {
+		fmt.Printf("    %q: %q\n", c.Tag, c.Canonical)
+	}
+}
+
+// plural returns a count followed by a noun, adding an s unless the count is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// printTree prints one line per node with its own count and the total including its
+// descendants, indenting children by two spaces.
+func printTree(nodes []*index.TagNode, depth int) {
+	for _, node := range nodes {
+		name := node.Name
+		if depth == 0 {
+			name = node.Tag
+		}
+		fmt.Printf("%s%s\t%d\t%d\n", strings.Repeat("  ", depth), name, node.Count, node.Total)
+		printTree(node.Children, depth+1)
+	}
+}
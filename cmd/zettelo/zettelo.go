@@ -2,18 +2,16 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
-	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/ozcankasal/zettelo/internal"
 	"github.com/ozcankasal/zettelo/internal/utils"
-
-	"github.com/fsnotify/fsnotify"
-	"github.com/gorilla/websocket"
 )
 
 const configContent = `
@@ -28,283 +26,271 @@ web:
 
 # Application-specific settings
 app:
+  # Keys starting with # must be quoted, or YAML reads them as comments.
   tag_mappings:
-    #todo: #todo
-    #to-do: #todo
-    #todo: #todo
+    "#to-do": "#todo"
+    "re:#todo:": "#todo"
+  # Tags that differ only in case, like #To-Do and #to-do, are the same tag.
+  fold_case: true
   folders:
     - /path/to/folder1
     - /path/to/folder2
 `
 
-func getConfigPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
+// Environment variables that override the defaults of the global flags.
+const (
+	envConfig = "ZETTELO_CONFIG"
+	envVault  = "ZETTELO_VAULT"
+)
 
-	configDir := filepath.Join(homeDir, ".zettelo")
-	if _, err := os.Stat(configDir); os.IsNotExist(err) {
-		if err := os.Mkdir(configDir, 0755); err != nil {
-			return "", err
-		}
-	}
+// Exit codes returned by the zettelo binary.
+const (
+	exitOK    = 0 // the command completed successfully
+	exitError = 1 // the command failed at runtime
+	exitUsage = 2 // the command line could not be parsed
+	exitScan  = 3 // the command completed, but some files could not be scanned
+)
 
-	configPath := filepath.Join(configDir, "config.yaml")
-	return configPath, nil
+// errPartialScan is returned by commands whose output is complete except for the
+// files that could not be scanned.
+var errPartialScan = errors.New("scan incomplete")
+
+const usageText = `Usage: zettelo [--config file] [--vault dir]... [--no-cache] <command> [arguments]
+
+Commands:
+  serve    start the web server and watch the vault for changes (default)
+  scan     scan the vault once and print the tag index as JSON
+  export   export the tag index, or notes, tags and their relations with
+           export graph [--format dot|graphml|gexf|json]
+  analyze  list orphans, dead ends, untagged notes, hubs, connected
+           components and bridge notes
+  ids      list note IDs, add missing ones with ids assign [--dry-run], check
+           them against the ID scheme with ids check, or print a new one
+           with ids next [--parent id]
+  links    report unresolved and ambiguous links with links check, or list
+           the notes linking to a note with links backlinks note...
+  tags     list tags and how often they are used, show them as a tree with
+           tags tree, show which mapping rule applies with tags explain tag...,
+           list tags used together with tags related tag..., or propose
+           tag_mappings for tags mostly used together with tags synonyms
+
+Global flags (accepted before or after the command):
+  --config file  configuration file (env ZETTELO_CONFIG, default ~/.zettelo/config.yaml)
+  --vault dir    folder to scan, may be repeated; replaces app.folders
+                 (env ZETTELO_VAULT, a list separated by the OS path list separator)
+  --no-cache     parse every note instead of reusing the parse results cached
+                 in ~/.zettelo/cache (or app.cache)
+
+Exit codes:
+  0  success
+  1  runtime error
+  2  usage error
+  3  the output was written, but some files could not be scanned
+`
+
+// options holds the global flags shared by every command.
+type options struct {
+	configPath string
+	vaults     stringList
+	noCache    bool
 }
 
-func createConfigFile(path string) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+// register adds the global flags to fs so they can follow the command name.
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.configPath, "config", o.configPath, "configuration `file`")
+	fs.Var(&o.vaults, "vault", "vault `dir` to scan, may be repeated")
+	fs.BoolVar(&o.noCache, "no-cache", o.noCache, "parse every note instead of using the index cache")
+}
 
-	_, err = file.WriteString(configContent)
-	if err != nil {
-		return err
-	}
+// stringList is a flag.Value collecting repeated string flags.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, string(os.PathListSeparator))
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
 	return nil
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// usageError marks errors caused by an invalid command line.
+type usageError struct {
+	msg string
 }
-var hashtags []byte
 
-func main() {
-	updates := make(chan []string)
-	// Initialize the file system watcher
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		fmt.Println(err)
-	}
-	defer watcher.Close()
+func (e usageError) Error() string {
+	return e.msg
+}
 
-	// Read configuration from environment variable
-	configPath, _ := getConfigPath()
+func newUsageError(format string, a ...interface{}) error {
+	return usageError{msg: fmt.Sprintf(format, a...)}
+}
 
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		if err := createConfigFile(configPath); err != nil {
-			fmt.Printf("Failed to create configuration file: %v\n", err)
-			os.Exit(1)
-		}
-	}
+type command struct {
+	name string
+	run  func(opts *options, args []string) error
+}
 
-	// read the configuration file
-	configData, err := ioutil.ReadFile(configPath)
-	if err != nil {
-		panic(err)
-	}
+var commands = []command{
+	{name: "serve", run: runServe},
+	{name: "scan", run: runScan},
+	{name: "export", run: runExport},
+	{name: "analyze", run: runAnalyze},
+	{name: "ids", run: runIDs},
+	{name: "links", run: runLinks},
+	{name: "tags", run: runTags},
+}
 
-	config, err := utils.ParseConfig(configData)
-	if err != nil {
-		fmt.Printf("Failed to read configuration: %v\n", err)
-		os.Exit(1)
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	opts := &options{}
+	fs := newFlagSet("zettelo", opts)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usageText) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
 	}
 
-	// Read folder from command line argument
-	folderList := config.App.Folders
-	if len(folderList) == 0 {
-		fmt.Println("No folders specified in configuration file.")
-		os.Exit(1)
+	name := "serve"
+	rest := fs.Args()
+	if len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
 	}
-	for _, folder := range folderList {
-		scanFolder(folder)
+	if name == "help" {
+		fmt.Print(usageText)
+		return exitOK
 	}
 
-	hashtags = getHashtags(folderList, config)
-
-	for folder := range folderList {
-		foldername := folderList[folder]
-		err = filepath.Walk(foldername, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				err = watcher.Add(path)
-				if err != nil {
-					fmt.Println(err)
-				}
-			}
-			return nil
-		})
-		if err != nil {
-			fmt.Println(err)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
 		}
-	}
-
-	// Start listening for events.
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Has(fsnotify.Write) {
-					hashtags = getHashtags(folderList, config)
-					updates <- []string{"update"}
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				log.Println("error:", err)
-			}
+		err := cmd.run(opts, rest)
+		if err == nil || errors.Is(err, flag.ErrHelp) {
+			return exitOK
 		}
-	}()
-
-	http.Handle("/", http.FileServer(http.Dir("./static")))
-
-	go handle(updates)
-
-	url := fmt.Sprintf("%s:%d", config.Web.Host, config.Web.Port)
-	fmt.Printf("Server is listening on %s. Click %s to open in browser.\n", url, url)
-
-	fmt.Println(http.ListenAndServe(url, nil))
-}
-
-func handle(updates chan []string) {
-	http.HandleFunc("/hashtags", func(w http.ResponseWriter, r *http.Request) {
-
-		conn, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			fmt.Println("Error upgrading connection:", err)
-			return
+		fmt.Fprintf(os.Stderr, "zettelo %s: %v\n", name, err)
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			return exitUsage
 		}
-		defer conn.Close()
-		conn.WriteMessage(websocket.TextMessage, hashtags)
-
-		for range updates {
-			conn.WriteMessage(websocket.TextMessage, hashtags)
+		if errors.Is(err, errPartialScan) {
+			return exitScan
 		}
+		return exitError
+	}
 
-	})
+	fmt.Fprintf(os.Stderr, "zettelo: unknown command %q\n\n%s", name, usageText)
+	return exitUsage
 }
 
-func getHashtags(folderList []string, config *internal.Config) []byte {
-	tempHashtagList := internal.TagList{}
-	for _, folderName := range folderList {
-		files, err := scanFolder(folderName)
-		if err != nil {
-			fmt.Printf("Failed to scan folder %s: %v\n", folderName, err)
-			os.Exit(1)
-		}
-
-		// Combine tagged lines from all files
-		taggedLinesByFile := extractTaggedLinesFromFiles(files, *config)
-
-		// Group tagged lines by canonical type and file path
-		groupedLines := groupTaggedLines(taggedLinesByFile)
-
-		// Convert to internal.TaggedLine slice
-		outputLines := convertToTaggedLines(groupedLines)
-
-		tempHashtagList = append(tempHashtagList, outputLines...)
+// newFlagSet returns a flag set with the global flags already registered.
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	opts.register(fs)
+	return fs
+}
 
+// parseFlags parses the arguments of a command and rejects unexpected positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{msg: err.Error()}
 	}
-
-	// Write JSON output to stdout
-	b, err := utils.WriteJSON(tempHashtagList)
-
-	if err != nil {
-		fmt.Printf("Failed to write JSON output: %v\n", err)
-		os.Exit(1)
+	if fs.NArg() > maxArgs {
+		return newUsageError("unexpected argument %q", fs.Arg(maxArgs))
 	}
-
-	return b
+	return nil
 }
 
-func getFolderNameFromArgs() string {
-	if len(os.Args) < 2 {
-		fmt.Println("Please provide a folder name.")
-		os.Exit(1)
+// splitSubcommand returns the leading subcommand of args, or def when args
+// starts with a flag, together with the remaining arguments.
+func splitSubcommand(args []string, def string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return def, args
 	}
-	return os.Args[1]
+	return args[0], args[1:]
 }
 
-// Scan folder recursively for markdown files
-func scanFolder(folderName string) ([]string, error) {
-	fmt.Println(folderName)
-	var files []string
-	err := filepath.Walk(folderName, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && filepath.Ext(path) == ".md" {
-			fmt.Println(path)
-			utils.SyncHeader(path)
-			files = append(files, path)
-		}
-		return nil
-	})
+func getConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return files, nil
-}
 
-func extractTaggedLinesFromFiles(files []string, config internal.Config) map[string]internal.TagList {
-	taggedLinesByFile := make(map[string]internal.TagList)
-	for _, file := range files {
-		data, err := ioutil.ReadFile(file)
-		if err != nil {
-			fmt.Printf("Failed to read file %s: %v\n", file, err)
-			os.Exit(1)
+	configDir := filepath.Join(homeDir, ".zettelo")
+	if _, err := os.Stat(configDir); os.IsNotExist(err) {
+		if err := os.Mkdir(configDir, 0755); err != nil {
+			return "", err
 		}
+	}
 
-		// Extract tagged lines from markdown file
-		lines := utils.ExtractTaggedLines(file, data, config)
+	configPath := filepath.Join(configDir, "config.yaml")
+	return configPath, nil
+}
 
-		// Append to taggedLinesByFile
-		taggedLinesByFile[file] = lines
+func createConfigFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
 	}
-	return taggedLinesByFile
+	defer file.Close()
+
+	_, err = file.WriteString(configContent)
+	if err != nil {
+		return err
+	}
+	return nil
 }
 
-// groupTaggedLines groups the tagged lines by canonical type and file path
-func groupTaggedLines(taggedLinesByFile map[string]internal.TagList) map[string]map[string][]internal.ResultValue {
-	groupedLines := make(map[string]map[string][]internal.ResultValue)
-
-	for file, lines := range taggedLinesByFile {
-		for _, line := range lines {
-			if len(line.Values) > 0 {
-				if _, ok := groupedLines[line.Tag]; !ok {
-					groupedLines[line.Tag] = make(map[string][]internal.ResultValue)
-				}
-				groupedLines[line.Tag][file] = append(groupedLines[line.Tag][file], line.Values...)
-			} else {
-				if _, ok := groupedLines[line.Tag]; !ok {
-					groupedLines[line.Tag] = make(map[string][]internal.ResultValue)
-				}
-				groupedLines[line.Tag][file] = append(groupedLines[line.Tag][file], internal.ResultValue{})
+// loadConfig reads the configuration selected by the --config flag, the
+// ZETTELO_CONFIG environment variable or the default location, in that order.
+// Only the default configuration file is created when it does not exist.
+func loadConfig(opts *options) (*internal.Config, error) {
+	configPath := opts.configPath
+	if configPath == "" {
+		configPath = os.Getenv(envConfig)
+	}
+	if configPath == "" {
+		defaultPath, err := getConfigPath()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(defaultPath); os.IsNotExist(err) {
+			if err := createConfigFile(defaultPath); err != nil {
+				return nil, fmt.Errorf("failed to create configuration file: %w", err)
 			}
 		}
+		configPath = defaultPath
 	}
 
-	return groupedLines
-}
-
-// convertGroupedLinesToTaggedLines converts the grouped lines to an internal.TaggedLine slice
-func convertToTaggedLines(groupedLines map[string]map[string][]internal.ResultValue) internal.TagList {
-	var outputLines internal.TagList
-	outputMap := make(map[string][]internal.ResultValue)
+	configData, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %w", err)
+	}
 
-	for tag, fileLines := range groupedLines {
-		for _, value := range fileLines {
-			outputMap[tag] = append(outputMap[tag], value...)
-		}
+	config, err := utils.ParseConfig(configData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration %s: %w", configPath, err)
 	}
 
-	for tag, values := range outputMap {
-		outputLines = append(outputLines, internal.TaggedLine{Tag: tag, Values: values})
+	if len(opts.vaults) > 0 {
+		config.App.Folders = opts.vaults
+	} else if env := os.Getenv(envVault); env != "" {
+		config.App.Folders = filepath.SplitList(env)
 	}
 
-	return outputLines
+	if len(config.App.Folders) == 0 {
+		return nil, errors.New("no folders specified in configuration file or with --vault")
+	}
+	return config, nil
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runZettelo runs the command line with the standard streams captured and returns the
+// exit code and what was printed.
+func runZettelo(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	stderr, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdout, stderr
+	code := run(args)
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+
+	out, _ := ioutil.ReadFile(stdout.Name())
+	errOut, _ := ioutil.ReadFile(stderr.Name())
+	return code, string(out), string(errOut)
+}
+
+// writeFiles creates the files below dir, with their folders.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testVault creates a vault and a configuration using it, with extra lines added to the
+// app section, and returns the folder of the vault and the path of the configuration.
+func testVault(t *testing.T, notes map[string]string, app string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	vault := filepath.Join(dir, "vault")
+	writeFiles(t, vault, notes)
+	config := filepath.Join(dir, "config.yaml")
+	writeFiles(t, dir, map[string]string{"config.yaml": "app:\n  folders:\n    - " + vault + "\n" + app})
+	// Keep the default configuration and the index cache out of the real home folder.
+	t.Setenv("HOME", dir)
+	t.Setenv(envConfig, "")
+	t.Setenv(envVault, "")
+	return vault, config
+}
+
+func TestRunExitCodes(t *testing.T) {
+	vault, config := testVault(t, map[string]string{"a.md": "# A #idea\n"}, "")
+	brokenVault := t.TempDir()
+	writeFiles(t, brokenVault, map[string]string{"a.md": "# A #idea\n"})
+	if err := os.Symlink(filepath.Join(brokenVault, "missing"), filepath.Join(brokenVault, "b.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"success", []string{"--config", config, "scan"}, exitOK},
+		{"help", []string{"help"}, exitOK},
+		{"missing configuration", []string{"--config", filepath.Join(vault, "missing.yaml"), "scan"}, exitError},
+		{"unknown command", []string{"--config", config, "frobnicate"}, exitUsage},
+		{"extra argument", []string{"--config", config, "scan", "extra"}, exitUsage},
+		{"unknown flag", []string{"--config", config, "scan", "--frobnicate"}, exitUsage},
+		{"partial scan", []string{"--config", config, "--vault", brokenVault, "scan"}, exitScan},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code, _, errOut := runZettelo(t, test.args...); code != test.code {
+				t.Errorf("Expected exit code %d, got %d: %s", test.code, code, errOut)
+			}
+		})
+	}
+}
+
+func TestRunConfigPrecedence(t *testing.T) {
+	_, flagConfig := testVault(t, map[string]string{"a.md": "#from-flag\n"}, "")
+	_, envConfigPath := testVault(t, map[string]string{"a.md": "#from-env\n"}, "")
+	// testVault points HOME at the folder of the last vault.
+	defaultVault, defaultConfig := testVault(t, map[string]string{"a.md": "#from-default\n"}, "")
+	home := filepath.Dir(defaultConfig)
+	writeFiles(t, home, map[string]string{filepath.Join(".zettelo", "config.yaml"): "app:\n  folders:\n    - " + defaultVault + "\n"})
+
+	tests := []struct {
+		name     string
+		env      string
+		args     []string
+		expected string
+	}{
+		{"flag before the environment", envConfigPath, []string{"--config", flagConfig, "scan"}, "#from-flag"},
+		{"flag after the command", envConfigPath, []string{"scan", "--config", flagConfig}, "#from-flag"},
+		{"environment before the default", envConfigPath, []string{"scan"}, "#from-env"},
+		{"default", "", []string{"scan"}, "#from-default"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(envConfig, test.env)
+			code, out, errOut := runZettelo(t, append([]string{"--no-cache"}, test.args...)...)
+			if code != exitOK || !strings.Contains(out, test.expected) {
+				t.Errorf("Expected %s and exit code 0, got %d: %s%s", test.expected, code, out, errOut)
+			}
+		})
+	}
+}
+
+func TestRunCreatesDefaultConfig(t *testing.T) {
+	testVault(t, nil, "")
+	home := os.Getenv("HOME")
+
+	// The generated configuration scans example folders that do not exist.
+	if code, _, errOut := runZettelo(t, "--no-cache", "scan"); code != exitScan {
+		t.Errorf("Expected exit code %d, got %d: %s", exitScan, code, errOut)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".zettelo", "config.yaml")); err != nil {
+		t.Errorf("Expected the default configuration to be created: %v", err)
+	}
+}
+
+func TestRunVaultFromEnvironment(t *testing.T) {
+	first, config := testVault(t, map[string]string{"a.md": "#first\n"}, "")
+	second := t.TempDir()
+	writeFiles(t, second, map[string]string{"b.md": "#second\n"})
+	t.Setenv(envVault, first+string(os.PathListSeparator)+second)
+
+	code, out, errOut := runZettelo(t, "--no-cache", "--config", config, "scan")
+	if code != exitOK || !strings.Contains(out, "#first") || !strings.Contains(out, "#second") {
+		t.Errorf("Expected the tags of both vaults, got %d: %s%s", code, out, errOut)
+	}
+
+	// --vault replaces the vaults of the environment.
+	code, out, _ = runZettelo(t, "--no-cache", "--config", config, "scan", "--vault", second)
+	if code != exitOK || strings.Contains(out, "#first") || !strings.Contains(out, "#second") {
+		t.Errorf("Expected only the tags of --vault, got %d: %s", code, out)
+	}
+}
+
+func TestRunExplainFoldsCaseByDefault(t *testing.T) {
+	testVault(t, nil, "")
+
+	// The generated configuration maps #to-do and folds case, so #To-Do is mapped too.
+	code, out, errOut := runZettelo(t, "tags", "explain", "#To-Do")
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, errOut)
+	}
+	if expected := "#To-Do -> #todo by tag_mappings \"#to-do\" (exact)\n"; out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
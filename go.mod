@@ -5,8 +5,12 @@ go 1.20
 require github.com/fsnotify/fsnotify v1.6.0
 
 require (
-	github.com/google/uuid v1.3.0
+	github.com/BurntSushi/toml v1.5.0
+	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.0
+	github.com/oklog/ulid/v2 v2.1.1
+	github.com/yuin/goldmark v1.7.8
 	golang.org/x/sys v0.0.0-20220908164124-27713097b956 // indirect
+	golang.org/x/text v0.21.0
 	gopkg.in/yaml.v2 v2.4.0
 )
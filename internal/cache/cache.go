@@ -0,0 +1,267 @@
+/*
+Package cache persists the parse results of notes between runs.
+
+Every entry is keyed by the path of the note and remembers its size, modification time
+and content hash. A note is only parsed again when it changed. The whole cache is
+discarded when it was written by a different parser or with a different extraction
+configuration.
+*/
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+// formatVersion changes whenever the layout of the cache file changes.
+const formatVersion = 2
+
+// Entry is the cached parse result of one note.
+type Entry struct {
+	Size    int64
+	ModTime int64 // in nanoseconds since the Unix epoch
+	Hash    string
+	Note    internal.Note
+}
+
+// Cache holds the entries of one vault. It is safe for concurrent use.
+type Cache struct {
+	path string
+	key  string
+
+	mu      sync.Mutex
+	entries map[string]Entry
+	dirty   bool
+}
+
+type cacheFile struct {
+	Key     string
+	Entries map[string]Entry
+}
+
+/*
+Key returns the key identifying the parser and the parts of the configuration that
+influence parsing. Entries written under a different key are never used.
+
+Usage:
+
+	key := cache.Key(*config)
+
+Parameters:
+
+	config (internal.Config): the configuration used to extract tags
+
+Returns:
+
+	(string): the cache key
+*/
+func Key(config internal.Config) string {
+	// json.Marshal sorts map keys, so equal configurations give equal keys.
+	settings, _ := json.Marshal(struct {
+		TagMappings          map[string]string
+		TagSynonyms          map[string][]string
+		FoldCase             bool
+		UnicodeNormalization string
+		SectionTags          bool
+		StructuredTags       bool
+		IDScheme             string
+		IDFilenamePrefix     bool
+	}{
+		config.App.TagMappings,
+		config.App.TagSynonyms,
+		config.App.FoldCase,
+		config.App.UnicodeNormalization,
+		config.App.SectionTags,
+		config.App.StructuredTags,
+		config.App.IDScheme,
+		config.App.IDFilenamePrefix,
+	})
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%d|%s", formatVersion, utils.ParserVersion, settings)))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+DefaultPath returns the cache file used for a set of folders: a file below
+~/.zettelo/cache named after the folders.
+
+Usage:
+
+	path, err := cache.DefaultPath(config.App.Folders)
+
+Parameters:
+
+	folders ([]string): the folders of the vault
+
+Returns:
+
+	(string): the path of the cache file
+	(error): if the home directory cannot be determined
+*/
+func DefaultPath(folders []string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	abs := make([]string, 0, len(folders))
+	for _, folder := range folders {
+		if p, err := filepath.Abs(folder); err == nil {
+			folder = p
+		}
+		abs = append(abs, folder)
+	}
+	sort.Strings(abs)
+	b, _ := json.Marshal(abs)
+	sum := sha256.Sum256(b)
+	return filepath.Join(homeDir, ".zettelo", "cache", hex.EncodeToString(sum[:8])+".gob"), nil
+}
+
+/*
+Load reads the cache file. A missing, unreadable or outdated cache file results in an
+empty cache, so loading never fails.
+
+Usage:
+
+	c := cache.Load(path, cache.Key(*config))
+
+Parameters:
+
+	path (string): the cache file
+	key (string): the current cache key
+
+Returns:
+
+	(*Cache): the cache
+*/
+func Load(path, key string) *Cache {
+	c := &Cache{path: path, key: key, entries: make(map[string]Entry)}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return c
+	}
+	defer file.Close()
+
+	var stored cacheFile
+	if err := gob.NewDecoder(file).Decode(&stored); err != nil || stored.Key != key {
+		// Outdated or corrupt: start over and replace the file on save.
+		c.dirty = true
+		return c
+	}
+	if stored.Entries != nil {
+		c.entries = stored.Entries
+	}
+	return c
+}
+
+// Len returns the number of cached notes.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Lookup returns the cached note at path if its size and modification time are unchanged.
+func (c *Cache) Lookup(path string, info os.FileInfo) (internal.Note, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[path]
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		return internal.Note{}, false
+	}
+	return entry.Note, true
+}
+
+// LookupHash returns the cached note at path if its content hash is unchanged. The size
+// and modification time of the entry are refreshed, so the next Lookup succeeds.
+func (c *Cache) LookupHash(path string, info os.FileInfo, hash string) (internal.Note, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[path]
+	if !ok || entry.Hash != hash {
+		return internal.Note{}, false
+	}
+	entry.Size = info.Size()
+	entry.ModTime = info.ModTime().UnixNano()
+	c.entries[path] = entry
+	c.dirty = true
+	return entry.Note, true
+}
+
+// Put stores the parse result of path.
+func (c *Cache) Put(path string, info os.FileInfo, hash string, note internal.Note) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[path] = Entry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Hash:    hash,
+		Note:    note,
+	}
+	c.dirty = true
+}
+
+// Prune removes the entries of every path for which keep returns false.
+func (c *Cache) Prune(keep func(path string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for path := range c.entries {
+		if !keep(path) {
+			delete(c.entries, path)
+			c.dirty = true
+		}
+	}
+}
+
+// Save writes the cache file if the cache changed since it was loaded. The file is
+// replaced atomically, so a crash never leaves a partial cache behind.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(cacheFile{Key: c.key, Entries: c.entries}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// Hash returns the content hash stored in cache entries.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,98 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/cache"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	note := filepath.Join(dir, "note.md")
+	if err := ioutil.WriteFile(note, []byte("#todo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := internal.Note{
+		Path:  note,
+		ID:    "1",
+		Tags:  []string{"a"},
+		Lines: internal.TagList{{Tag: "#todo", Values: []internal.ResultValue{{FilePath: note, Line: "x"}}}},
+	}
+	cachePath := filepath.Join(dir, "cache", "vault.gob")
+
+	c := cache.Load(cachePath, "key")
+	c.Put(note, info, cache.Hash([]byte("#todo")), parsed)
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded := cache.Load(cachePath, "key")
+	cached, ok := loaded.Lookup(note, info)
+	if !ok || !reflect.DeepEqual(cached, parsed) {
+		t.Errorf("Expected %v from the cache, got %v (%v)", parsed, cached, ok)
+	}
+	if _, ok := loaded.LookupHash(note, info, cache.Hash([]byte("#idea"))); ok {
+		t.Errorf("Expected a different hash to miss")
+	}
+
+	loaded.Prune(func(string) bool { return false })
+	if loaded.Len() != 0 {
+		t.Errorf("Expected Prune to remove every entry")
+	}
+}
+
+func TestLoadDiscardsOutdatedCache(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "vault.gob")
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := cache.Load(cachePath, "old")
+	c.Put("note.md", info, "hash", internal.Note{})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded := cache.Load(cachePath, "new"); loaded.Len() != 0 {
+		t.Errorf("Expected a cache with a different key to be discarded")
+	}
+
+	if err := ioutil.WriteFile(cachePath, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := cache.Load(cachePath, "old"); loaded.Len() != 0 {
+		t.Errorf("Expected a corrupt cache to be discarded")
+	}
+}
+
+func TestKeyDependsOnTagMappings(t *testing.T) {
+	var a, b internal.Config
+	b.App.TagMappings = map[string]string{"#to-do": "#todo"}
+	if cache.Key(a) == cache.Key(b) {
+		t.Errorf("Expected different tag mappings to change the key")
+	}
+	if cache.Key(a) != cache.Key(internal.Config{}) {
+		t.Errorf("Expected equal configurations to give the same key")
+	}
+
+	var c internal.Config
+	c.App.SectionTags = true
+	if cache.Key(a) == cache.Key(c) {
+		t.Errorf("Expected section tags to change the key")
+	}
+	c.App.SectionTags, c.App.StructuredTags = false, true
+	if cache.Key(a) == cache.Key(c) {
+		t.Errorf("Expected structured tags to change the key")
+	}
+}
@@ -0,0 +1,580 @@
+/*
+Package frontmatter reads and edits the metadata block at the start of a note.
+
+Three formats are recognised:
+
+	---              +++              {
+	id: 1            id = "1"           "id": "1"
+	type: concept    type = "concept"   "type": "concept"
+	---              +++              }
+
+The block may follow a UTF-8 byte order mark and may use CRLF line endings. Edits change
+a single top-level key and keep the rest of the note, including comments and the order
+of the keys, byte for byte.
+*/
+package frontmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Formats of front matter.
+const (
+	YAML = "yaml"
+	TOML = "toml"
+	JSON = "json"
+)
+
+var bom = []byte("\xef\xbb\xbf")
+
+// FrontMatter is the metadata block of a note.
+type FrontMatter struct {
+	Format string
+	// Fields holds the decoded keys of the block.
+	Fields map[string]interface{}
+	// Start and End delimit the block in the note, from its opening delimiter to the
+	// line ending after its closing delimiter.
+	Start, End int
+
+	// bodyStart and bodyEnd delimit the text between the delimiters; for JSON the
+	// braces are part of it.
+	bodyStart, bodyEnd int
+	newline            string
+}
+
+/*
+Parse reads the front matter at the start of a note.
+
+Usage:
+
+	fm, err := frontmatter.Parse(content)
+	if fm != nil {
+		id := fm.String("id")
+	}
+
+Parameters:
+
+	content ([]byte): the note
+
+Returns:
+
+	(*FrontMatter): the front matter, or nil if the note has none
+	(error): if the front matter could not be decoded; the returned front matter then has no fields
+*/
+func Parse(content []byte) (*FrontMatter, error) {
+	fm := locate(content)
+	if fm == nil {
+		return nil, nil
+	}
+
+	body := content[fm.bodyStart:fm.bodyEnd]
+	var err error
+	switch fm.Format {
+	case YAML:
+		err = yaml.Unmarshal(body, &fm.Fields)
+	case TOML:
+		err = toml.Unmarshal(body, &fm.Fields)
+	case JSON:
+		err = json.Unmarshal(body, &fm.Fields)
+	}
+	if err != nil {
+		fm.Fields = map[string]interface{}{}
+		return fm, fmt.Errorf("invalid %s front matter: %w", strings.ToUpper(fm.Format), err)
+	}
+	if fm.Fields == nil {
+		fm.Fields = map[string]interface{}{}
+	}
+	return fm, nil
+}
+
+// locate finds the delimiters of the front matter without decoding it.
+func locate(content []byte) *FrontMatter {
+	start := 0
+	if bytes.HasPrefix(content, bom) {
+		start = len(bom)
+	}
+	first, next, newline := readLine(content, start)
+
+	var format string
+	var closers []string
+	switch strings.TrimRight(first, " \t") {
+	case "---":
+		format, closers = YAML, []string{"---", "..."}
+	case "+++":
+		format, closers = TOML, []string{"+++"}
+	default:
+		if strings.HasPrefix(first, "{") {
+			return locateJSON(content, start, newline)
+		}
+		return nil
+	}
+
+	for pos := next; pos < len(content); {
+		text, after, _ := readLine(content, pos)
+		for _, closer := range closers {
+			if strings.TrimRight(text, " \t") == closer {
+				return &FrontMatter{Format: format, Start: start, End: after, bodyStart: next, bodyEnd: pos, newline: newline}
+			}
+		}
+		pos = after
+	}
+	return nil
+}
+
+// locateJSON finds a JSON object at the start of a note that ends a line.
+func locateJSON(content []byte, start int, newline string) *FrontMatter {
+	dec := json.NewDecoder(bytes.NewReader(content[start:]))
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return nil
+	}
+	end := start + int(dec.InputOffset())
+	rest, after, _ := readLine(content, end)
+	if strings.TrimSpace(rest) != "" {
+		return nil
+	}
+	return &FrontMatter{Format: JSON, Start: start, End: after, bodyStart: start, bodyEnd: end, newline: newline}
+}
+
+// readLine returns the line starting at pos without its line ending, the position of
+// the next line and the line ending.
+func readLine(content []byte, pos int) (string, int, string) {
+	end := bytes.IndexByte(content[pos:], '\n')
+	if end < 0 {
+		return string(content[pos:]), len(content), ""
+	}
+	end += pos
+	if end > pos && content[end-1] == '\r' {
+		return string(content[pos : end-1]), end + 1, "\r\n"
+	}
+	return string(content[pos:end]), end + 1, "\n"
+}
+
+/*
+String returns a scalar field as a string. Numbers and booleans are formatted, dates as
+2006-01-02 or RFC 3339.
+
+Usage:
+
+	noteType := fm.String("type")
+
+Parameters:
+
+	key (string): the name of the field
+
+Returns:
+
+	(string): the value, or an empty string if the field is missing or not a scalar
+*/
+func (f *FrontMatter) String(key string) string {
+	if f == nil {
+		return ""
+	}
+	return scalar(f.Fields[key])
+}
+
+/*
+List returns a field as a list of strings. A list gives its scalar items and a string is
+split at commas, so both "tags: [a, b]" and "tags: a, b" give a and b.
+
+Usage:
+
+	tags := fm.List("tags")
+
+Parameters:
+
+	key (string): the name of the field
+
+Returns:
+
+	([]string): the non-empty items, or nil if the field is missing
+*/
+func (f *FrontMatter) List(key string) []string {
+	if f == nil {
+		return nil
+	}
+	var items []string
+	switch v := f.Fields[key].(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s := scalar(item); s != "" {
+				items = append(items, s)
+			}
+		}
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if s := strings.TrimSpace(item); s != "" {
+				items = append(items, s)
+			}
+		}
+	default:
+		if s := scalar(v); s != "" {
+			items = append(items, s)
+		}
+	}
+	return items
+}
+
+/*
+Line returns the 1-based line number of a top-level key in the note. A key that cannot be
+found gives the line of the opening delimiter.
+
+Usage:
+
+	line := fm.Line(content, "tags")
+
+Parameters:
+
+	content ([]byte): the note the front matter was parsed from
+	key (string): the name of the field
+
+Returns:
+
+	(int): the line number, or 0 if f is nil
+*/
+func (f *FrontMatter) Line(content []byte, key string) int {
+	if f == nil {
+		return 0
+	}
+	pos := f.Start
+	switch f.Format {
+	case YAML, TOML:
+		re, stop := yamlKeyRegex(key), (*regexp.Regexp)(nil)
+		if f.Format == TOML {
+			re, stop = tomlKeyRegex(key), tableRegex
+		}
+		for p := f.bodyStart; p < f.bodyEnd; {
+			text, next, _ := readLine(content, p)
+			if stop != nil && stop.MatchString(text) {
+				break
+			}
+			if re.MatchString(text) {
+				pos = p
+				break
+			}
+			p = next
+		}
+	case JSON:
+		dec := json.NewDecoder(bytes.NewReader(content[f.bodyStart:f.bodyEnd]))
+		if _, err := dec.Token(); err != nil {
+			break
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				break
+			}
+			if tok == key {
+				pos = f.bodyStart + int(dec.InputOffset())
+				break
+			}
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				break
+			}
+		}
+	}
+	return bytes.Count(content[:pos], []byte("\n")) + 1
+}
+
+func scalar(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		if v.Equal(time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, v.Location())) {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// keyRegex matches the keys Set can write without quoting.
+var keyRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+/*
+Set returns the note with a top-level key of its front matter set to a string value.
+A key that exists is replaced in place, keeping a comment on its line; a new key is
+added after the other top-level keys. A note without front matter gets a YAML block
+using the line endings of the note.
+
+Usage:
+
+	newContent, err := frontmatter.Set(content, "id", "e9eb51f7-0706-4eb8-a343-6c0c7f4f6e4d")
+
+Parameters:
+
+	content ([]byte): the note
+	key (string): the name of the field, made of letters, digits, '_' and '-'
+	value (string): the value of the field
+
+Returns:
+
+	([]byte): the updated note
+	(error): if the key is not valid or the front matter cannot be decoded
+*/
+func Set(content []byte, key, value string) ([]byte, error) {
+	if !keyRegex.MatchString(key) {
+		return nil, fmt.Errorf("invalid front matter key %q", key)
+	}
+	fm, err := Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var newContent []byte
+	switch {
+	case fm == nil:
+		start := 0
+		if bytes.HasPrefix(content, bom) {
+			start = len(bom)
+		}
+		newline := "\n"
+		if i := bytes.IndexByte(content, '\n'); i > 0 && content[i-1] == '\r' {
+			newline = "\r\n"
+		}
+		block := "---" + newline + key + ": " + yamlValue(value) + newline + "---" + newline
+		newContent = splice(content, start, start, []byte(block))
+	case fm.Format == YAML:
+		newContent = setLine(content, fm, yamlKeyRegex(key), key+": "+yamlValue(value), nil)
+	case fm.Format == TOML:
+		newContent = setLine(content, fm, tomlKeyRegex(key), key+" = "+jsonValue(value), tableRegex)
+	default:
+		newContent, err = setJSON(content, fm, key, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Make sure the edit reads back as intended.
+	check, err := Parse(newContent)
+	if err != nil || check.String(key) != strings.TrimSpace(value) {
+		return nil, fmt.Errorf("cannot set front matter key %q", key)
+	}
+	return newContent, nil
+}
+
+// splice returns content with content[start:end] replaced by insert.
+func splice(content []byte, start, end int, insert []byte) []byte {
+	result := make([]byte, 0, len(content)-(end-start)+len(insert))
+	result = append(result, content[:start]...)
+	result = append(result, insert...)
+	return append(result, content[end:]...)
+}
+
+// yamlValue returns value as a YAML scalar, quoting it unless it reads back unchanged.
+func yamlValue(value string) string {
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal([]byte("k: "+value), &fields); err == nil {
+		if scalar(fields["k"]) == value && !strings.ContainsAny(value, "\r\n") {
+			return value
+		}
+	}
+	return jsonValue(value)
+}
+
+// jsonValue returns value as a double-quoted string, which is valid JSON, YAML and TOML.
+func jsonValue(value string) string {
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+func yamlKeyRegex(key string) *regexp.Regexp {
+	k := regexp.QuoteMeta(key)
+	return regexp.MustCompile(`^(?:` + k + `|"` + k + `"|'` + k + `')[ \t]*:(?:[ \t]|$)`)
+}
+
+func tomlKeyRegex(key string) *regexp.Regexp {
+	k := regexp.QuoteMeta(key)
+	return regexp.MustCompile(`^[ \t]*(?:` + k + `|"` + k + `"|'` + k + `')[ \t]*=`)
+}
+
+// tableRegex matches a TOML table header, which ends the top-level keys.
+var tableRegex = regexp.MustCompile(`^[ \t]*\[`)
+
+// setLine replaces the line of a key in a YAML or TOML block, together with the lines
+// continuing its value, or adds the line after the top-level keys. The top-level keys
+// end at the first line matching stop, if any.
+func setLine(content []byte, fm *FrontMatter, key *regexp.Regexp, line string, stop *regexp.Regexp) []byte {
+	type bodyLine struct {
+		text       string
+		start, end int
+	}
+	var lines []bodyLine
+	for pos := fm.bodyStart; pos < fm.bodyEnd; {
+		text, next, _ := readLine(content, pos)
+		lines = append(lines, bodyLine{text, pos, next})
+		pos = next
+	}
+	top := len(lines)
+	if stop != nil {
+		for i, l := range lines {
+			if stop.MatchString(l.text) {
+				top = i
+				break
+			}
+		}
+	}
+
+	for i := 0; i < top; i++ {
+		if !key.MatchString(lines[i].text) {
+			continue
+		}
+		j := i + 1
+		if fm.Format == YAML {
+			// Indented lines and block sequence items continue the value.
+			for j < top && (strings.TrimSpace(lines[j].text) == "" || lines[j].text[0] == ' ' || lines[j].text[0] == '\t' || strings.HasPrefix(lines[j].text, "- ")) {
+				j++
+			}
+			for j > i+1 && strings.TrimSpace(lines[j-1].text) == "" {
+				j--
+			}
+		} else {
+			j = i + tomlValueLines(lines[i].text, func(k int) (string, bool) {
+				if i+k < top {
+					return lines[i+k].text, true
+				}
+				return "", false
+			})
+		}
+		comment := ""
+		if j == i+1 {
+			comment = trailingComment(lines[i].text[key.FindStringIndex(lines[i].text)[1]:])
+		}
+		end := lines[j-1].end - lineEndingLength(content, lines[j-1].end)
+		return splice(content, lines[i].start, end, []byte(line+comment))
+	}
+
+	// Add the key after the last top-level line that is not blank.
+	insertAt := fm.bodyStart
+	for i := top - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i].text) != "" {
+			insertAt = lines[i].end
+			break
+		}
+	}
+	return splice(content, insertAt, insertAt, []byte(line+fm.newline))
+}
+
+// lineEndingLength returns the length of the line ending before pos.
+func lineEndingLength(content []byte, pos int) int {
+	switch {
+	case pos >= 2 && content[pos-2] == '\r' && content[pos-1] == '\n':
+		return 2
+	case pos >= 1 && content[pos-1] == '\n':
+		return 1
+	}
+	return 0
+}
+
+// tomlValueLines returns the number of lines taken by the value of a TOML key line:
+// arrays and multi-line strings may continue on the following lines.
+func tomlValueLines(first string, next func(int) (string, bool)) int {
+	value := strings.TrimSpace(first[strings.IndexByte(first, '=')+1:])
+	for _, quote := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(value, quote) && !strings.Contains(value[3:], quote) {
+			for k := 1; ; k++ {
+				text, ok := next(k)
+				if !ok || strings.Contains(text, quote) {
+					return k + 1
+				}
+			}
+		}
+	}
+	if strings.HasPrefix(value, "[") {
+		depth := strings.Count(value, "[") - strings.Count(value, "]")
+		for k := 1; depth > 0; k++ {
+			text, ok := next(k)
+			if !ok {
+				return k
+			}
+			depth += strings.Count(text, "[") - strings.Count(text, "]")
+			if depth <= 0 {
+				return k + 1
+			}
+		}
+	}
+	return 1
+}
+
+// trailingComment returns the comment at the end of a single-line value, including the
+// whitespace before it, or an empty string.
+func trailingComment(value string) string {
+	i := len(value) - len(strings.TrimLeft(value, " \t"))
+	if i < len(value) && (value[i] == '"' || value[i] == '\'') {
+		// Skip a quoted string, which may contain '#'.
+		if end := strings.IndexByte(value[i+1:], value[i]); end >= 0 {
+			i += end + 2
+		}
+	}
+	for ; i < len(value); i++ {
+		if value[i] == '#' && i > 0 && (value[i-1] == ' ' || value[i-1] == '\t') {
+			start := i
+			for start > 0 && (value[start-1] == ' ' || value[start-1] == '\t') {
+				start--
+			}
+			return value[start:]
+		}
+	}
+	return ""
+}
+
+// setJSON replaces the value of a key of a JSON block, or adds the key after the others.
+func setJSON(content []byte, fm *FrontMatter, key, value string) ([]byte, error) {
+	body := content[fm.bodyStart:fm.bodyEnd]
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	lastEnd := -1
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		end := int(dec.InputOffset())
+		if tok == key {
+			start := fm.bodyStart + end - len(raw)
+			return splice(content, start, fm.bodyStart+end, []byte(jsonValue(value))), nil
+		}
+		lastEnd = end
+	}
+
+	entry := jsonValue(key) + ": " + jsonValue(value)
+	open := bytes.IndexByte(body, '{') + 1
+	if lastEnd < 0 {
+		// An empty object.
+		return splice(content, fm.bodyStart+open, fm.bodyEnd-1, []byte(fm.newline+"  "+entry+fm.newline)), nil
+	}
+	// Separate the new key from the previous one like the first key from the brace.
+	ws := body[open : open+bytes.IndexByte(body[open:], '"')]
+	if len(ws) == 0 {
+		ws = []byte(" ")
+	}
+	return splice(content, fm.bodyStart+lastEnd, fm.bodyStart+lastEnd, []byte(","+string(ws)+entry)), nil
+}
@@ -0,0 +1,252 @@
+package frontmatter_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal/frontmatter"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		format  string
+		end     int
+	}{
+		{name: "yaml", content: "---\nid: 1\ntype: concept\n---\nbody", format: frontmatter.YAML, end: 28},
+		{name: "yaml end marker", content: "---\nid: 1\ntype: concept\n...\nbody", format: frontmatter.YAML, end: 28},
+		{name: "crlf and bom", content: "\xef\xbb\xbf---\r\nid: 1\r\ntype: concept\r\n---\r\nbody", format: frontmatter.YAML, end: 35},
+		{name: "toml", content: "+++\nid = \"1\"\ntype = \"concept\"\n+++\n", format: frontmatter.TOML, end: 34},
+		{name: "json", content: "{\n  \"id\": \"1\",\n  \"type\": \"concept\"\n}\nbody", format: frontmatter.JSON, end: 37},
+		{name: "at end of file", content: "---\nid: 1\ntype: concept\n---", format: frontmatter.YAML, end: 27},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fm, err := frontmatter.Parse([]byte(testCase.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fm == nil {
+				t.Fatal("Expected front matter")
+			}
+			if fm.Format != testCase.format || fm.End != testCase.end {
+				t.Errorf("Expected %s ending at %d, got %s ending at %d", testCase.format, testCase.end, fm.Format, fm.End)
+			}
+			if fm.String("id") != "1" || fm.String("type") != "concept" {
+				t.Errorf("Unexpected fields %v", fm.Fields)
+			}
+		})
+	}
+}
+
+func TestParseWithoutFrontMatter(t *testing.T) {
+	for _, content := range []string{"", "# Title\n", "---\nno end\n", "{ not json\n", "--- \n", "{\"a\": 1} text\n"} {
+		if fm, err := frontmatter.Parse([]byte(content)); fm != nil || err != nil {
+			t.Errorf("%q: expected no front matter, got %v %v", content, fm, err)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	fm, err := frontmatter.Parse([]byte("---\nid: [1\n---\nbody\n"))
+	if err == nil || fm == nil {
+		t.Fatalf("Expected an error with the block, got %v %v", fm, err)
+	}
+	if fm.End != 15 || len(fm.Fields) != 0 {
+		t.Errorf("Unexpected front matter %+v", fm)
+	}
+}
+
+func TestFields(t *testing.T) {
+	fm, err := frontmatter.Parse([]byte(`---
+id: 202610181200
+title: "A note"
+tags: algebra, equations , proof
+aliases: [one, two]
+created: 2026-10-18
+draft: true
+---
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fm.String("id"); got != "202610181200" {
+		t.Errorf("Unexpected id %q", got)
+	}
+	if got := fm.String("title"); got != "A note" {
+		t.Errorf("Unexpected title %q", got)
+	}
+	if got := fm.String("draft"); got != "true" {
+		t.Errorf("Unexpected draft %q", got)
+	}
+	if got := fm.List("tags"); !reflect.DeepEqual(got, []string{"algebra", "equations", "proof"}) {
+		t.Errorf("Unexpected tags %q", got)
+	}
+	if got := fm.List("aliases"); !reflect.DeepEqual(got, []string{"one", "two"}) {
+		t.Errorf("Unexpected aliases %q", got)
+	}
+	if got := fm.String("aliases"); got != "" {
+		t.Errorf("Expected a list not to be a string, got %q", got)
+	}
+	if got := fm.List("missing"); got != nil {
+		t.Errorf("Expected no items, got %q", got)
+	}
+
+	toml, err := frontmatter.Parse([]byte("+++\ncreated = 2026-10-18\ntags = [\"a\", \"b\"]\n+++\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toml.String("created"); got != "2026-10-18" {
+		t.Errorf("Unexpected date %q", got)
+	}
+	if got := toml.List("tags"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Unexpected tags %q", got)
+	}
+}
+
+func TestLine(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected int
+	}{
+		{"yaml", "---\nid: 1\ntitle: A\ntags: [a]\n---\n", 4},
+		{"yaml missing key", "---\nid: 1\n---\n", 1},
+		{"yaml nested key", "---\nmeta:\n  tags: [a]\ntags: [b]\n---\n", 4},
+		{"toml", "+++\nid = 1\ntags = [\"a\"]\n+++\n", 3},
+		{"toml table", "+++\nid = 1\n[extra]\ntags = [\"a\"]\n+++\n", 1},
+		{"json", "{\n  \"id\": \"1\",\n  \"tags\": [\"a\"]\n}\n", 3},
+		{"crlf", "\ufeff---\r\nid: 1\r\ntags: [a]\r\n---\r\n", 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content := []byte(tc.content)
+			fm, err := frontmatter.Parse(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fm.Line(content, "tags"); got != tc.expected {
+				t.Errorf("Expected line %d, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		key      string
+		value    string
+		expected string
+	}{
+		{
+			name:     "no front matter",
+			content:  "# Title\n",
+			key:      "id",
+			value:    "abc",
+			expected: "---\nid: abc\n---\n# Title\n",
+		},
+		{
+			name:     "no front matter with crlf and bom",
+			content:  "\xef\xbb\xbf# Title\r\n",
+			key:      "id",
+			value:    "abc",
+			expected: "\xef\xbb\xbf---\r\nid: abc\r\n---\r\n# Title\r\n",
+		},
+		{
+			name:     "yaml add keeps comments and order",
+			content:  "---\n# about the note\ntitle: A # the title\ntype: concept\n\n---\nbody\n",
+			key:      "id",
+			value:    "abc",
+			expected: "---\n# about the note\ntitle: A # the title\ntype: concept\nid: abc\n\n---\nbody\n",
+		},
+		{
+			name:     "yaml replace keeps the comment",
+			content:  "---\ntitle: A\nid: old # generated\ntype: concept\n---\n",
+			key:      "id",
+			value:    "new",
+			expected: "---\ntitle: A\nid: new # generated\ntype: concept\n---\n",
+		},
+		{
+			name:     "yaml replace block value",
+			content:  "---\ntags:\n  - a\n  - b\n\ntype: concept\n---\n",
+			key:      "tags",
+			value:    "c",
+			expected: "---\ntags: c\n\ntype: concept\n---\n",
+		},
+		{
+			name:     "yaml quotes values that would change",
+			content:  "---\ntitle: A\n---\n",
+			key:      "id",
+			value:    "0012",
+			expected: "---\ntitle: A\nid: \"0012\"\n---\n",
+		},
+		{
+			name:     "yaml crlf",
+			content:  "---\r\ntitle: A\r\n---\r\n",
+			key:      "id",
+			value:    "abc",
+			expected: "---\r\ntitle: A\r\nid: abc\r\n---\r\n",
+		},
+		{
+			name:     "toml add before tables",
+			content:  "+++\ntitle = \"A\" # the title\n\n[extra]\nid = \"x\"\n+++\n",
+			key:      "id",
+			value:    "abc",
+			expected: "+++\ntitle = \"A\" # the title\nid = \"abc\"\n\n[extra]\nid = \"x\"\n+++\n",
+		},
+		{
+			name:     "toml replace",
+			content:  "+++\nid = \"old\" # generated\ntags = [\n  \"a\",\n]\n+++\n",
+			key:      "tags",
+			value:    "b",
+			expected: "+++\nid = \"old\" # generated\ntags = \"b\"\n+++\n",
+		},
+		{
+			name:     "json add",
+			content:  "{\n  \"title\": \"A\"\n}\nbody\n",
+			key:      "id",
+			value:    "abc",
+			expected: "{\n  \"title\": \"A\",\n  \"id\": \"abc\"\n}\nbody\n",
+		},
+		{
+			name:     "json replace",
+			content:  "{\"id\": \"old\", \"title\": \"A\"}\n",
+			key:      "id",
+			value:    "new",
+			expected: "{\"id\": \"new\", \"title\": \"A\"}\n",
+		},
+		{
+			name:     "json empty object",
+			content:  "{}\n",
+			key:      "id",
+			value:    "abc",
+			expected: "{\n  \"id\": \"abc\"\n}\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got, err := frontmatter.Set([]byte(testCase.content), testCase.key, testCase.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != testCase.expected {
+				t.Errorf("Expected %q, got %q", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	if _, err := frontmatter.Set([]byte("---\nid: [1\n---\n"), "id", "x"); err == nil {
+		t.Errorf("Expected an error for invalid front matter")
+	}
+	if _, err := frontmatter.Set([]byte("# Title\n"), "a b", "x"); err == nil {
+		t.Errorf("Expected an error for an invalid key")
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Package hub fans out messages to every connected websocket client.
+
+Each client has its own bounded send queue. A client that cannot keep up with the
+broadcasts is dropped instead of blocking the hub, so a stalled browser tab never
+stalls the producer of the messages.
+*/
+package hub
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to the client.
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong message from the client.
+	pongWait = 60 * time.Second
+
+	// Send pings to the client with this period. Must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+
+	// Maximum message size allowed from the client.
+	maxMessageSize = 512
+
+	// Number of messages queued for a client before it is considered too slow.
+	sendQueueSize = 16
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// Hub keeps track of the connected clients and broadcasts messages to them.
+type Hub struct {
+	// current returns the message sent to a client as soon as it connects.
+	current func() []byte
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+}
+
+type client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+	once sync.Once
+}
+
+/*
+New creates a hub.
+
+Usage:
+
+	h := hub.New(func() []byte { return latest })
+	http.Handle("/hashtags", h)
+
+Parameters:
+
+	current (func() []byte): returns the message each client receives on connect; may be
+	nil. It is called with the hub locked and must not call the hub.
+
+Returns:
+
+	(*Hub): the hub, ready to serve websocket connections
+*/
+func New(current func() []byte) *Hub {
+	return &Hub{
+		current: current,
+		clients: make(map[*client]struct{}),
+	}
+}
+
+// ServeHTTP upgrades the request to a websocket connection and registers the client.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied to the client with an error.
+		return
+	}
+
+	c := &client{hub: h, conn: conn, send: make(chan []byte, sendQueueSize)}
+	h.register(c)
+
+	go c.writePump()
+	go c.readPump()
+}
+
+/*
+Broadcast queues the message for every connected client. It never blocks: clients whose
+send queue is full are dropped.
+
+Usage:
+
+	h.Broadcast(message)
+
+Parameters:
+
+	msg ([]byte): the message to send
+*/
+func (h *Hub) Broadcast(msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		select {
+		case c.send <- msg:
+		default:
+			// The client is too slow; drop it rather than wait.
+			delete(h.clients, c)
+			c.close()
+		}
+	}
+}
+
+// ClientCount returns the number of connected clients.
+func (h *Hub) ClientCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// register adds the client and queues the current message for it. Both happen under the
+// lock, so every later broadcast is queued after the current message and none is lost.
+func (h *Hub) register(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = struct{}{}
+	if h.current != nil {
+		if msg := h.current(); msg != nil {
+			c.send <- msg
+		}
+	}
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		c.close()
+	}
+}
+
+// close closes the send queue, which makes the write pump close the connection.
+func (c *client) close() {
+	c.once.Do(func() { close(c.send) })
+}
+
+// readPump discards incoming messages and keeps the read deadline fresh on every pong.
+// It unregisters the client once the connection fails.
+func (c *client) readPump() {
+	defer func() {
+		c.hub.unregister(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump writes queued messages and periodic pings to the connection.
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// The hub dropped the client.
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.hub.unregister(c)
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.hub.unregister(c)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package hub_test
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ozcankasal/zettelo/internal/hub"
+)
+
+func dial(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial %s: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	return string(msg)
+}
+
+func waitForClients(t *testing.T, h *hub.Hub, expected int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for h.ClientCount() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d clients, got %d", expected, h.ClientCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBroadcastReachesEveryClient(t *testing.T) {
+	h := hub.New(func() []byte { return []byte("initial") })
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	first := dial(t, server)
+	second := dial(t, server)
+	waitForClients(t, h, 2)
+
+	for _, conn := range []*websocket.Conn{first, second} {
+		if msg := readMessage(t, conn); msg != "initial" {
+			t.Errorf("Expected initial message, got %q", msg)
+		}
+	}
+
+	h.Broadcast([]byte("update"))
+	for _, conn := range []*websocket.Conn{first, second} {
+		if msg := readMessage(t, conn); msg != "update" {
+			t.Errorf("Expected update message, got %q", msg)
+		}
+	}
+}
+
+func TestClientConnectingDuringBroadcastsEndsCurrent(t *testing.T) {
+	var mu sync.Mutex
+	latest := "0"
+	h := hub.New(func() []byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return []byte(latest)
+	})
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	// Clients connect while new messages are published and broadcast. Whatever the
+	// interleaving, the last message each client receives must be the last one published.
+	const last = 100
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= last; i++ {
+			mu.Lock()
+			latest = strconv.Itoa(i)
+			mu.Unlock()
+			h.Broadcast([]byte(strconv.Itoa(i)))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	var conns []*websocket.Conn
+	for i := 0; i < 10; i++ {
+		conns = append(conns, dial(t, server))
+		time.Sleep(5 * time.Millisecond)
+	}
+	<-done
+
+	// readMessage fails the test if a client never receives the last message.
+	for _, conn := range conns {
+		for msg := ""; msg != strconv.Itoa(last); {
+			msg = readMessage(t, conn)
+		}
+	}
+}
+
+func TestBroadcastWithoutClientsDoesNotBlock(t *testing.T) {
+	h := hub.New(nil)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			h.Broadcast([]byte("update"))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Broadcast blocked without clients")
+	}
+}
+
+func TestSlowClientIsDropped(t *testing.T) {
+	h := hub.New(nil)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	// The client never reads, so its send queue eventually fills up.
+	dial(t, server)
+	waitForClients(t, h, 1)
+
+	msg := []byte(strings.Repeat("x", 1<<20))
+	for i := 0; i < 1000 && h.ClientCount() > 0; i++ {
+		h.Broadcast(msg)
+	}
+	waitForClients(t, h, 0)
+}
+
+func TestClosedClientIsUnregistered(t *testing.T) {
+	h := hub.New(nil)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	conn := dial(t, server)
+	waitForClients(t, h, 1)
+
+	conn.Close()
+	waitForClients(t, h, 0)
+}
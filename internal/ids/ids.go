@@ -0,0 +1,265 @@
+/*
+Package ids generates and validates note IDs.
+
+The scheme is selected with app.id_scheme:
+
+	uuid4        e9eb51f7-0706-4eb8-a343-6c0c7f4f6e4d (the default)
+	uuid7        01927b2e-7c4a-7d1e-9f3b-6a1c2d3e4f50, ordered by creation time
+	ulid         01J9XQ3ZK8M4N6P7R8S9T0V1W2, ordered by creation time
+	timestamp    202610181230, the minute of creation
+	folgezettel  1, 1a, 1a1, 1a2, 1b, 2: Luhmann's branching sequence
+*/
+package ids
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+// Names of the schemes.
+const (
+	UUID4       = "uuid4"
+	UUID7       = "uuid7"
+	ULID        = "ulid"
+	Timestamp   = "timestamp"
+	Folgezettel = "folgezettel"
+)
+
+// Schemes lists the names of the schemes.
+var Schemes = []string{UUID4, UUID7, ULID, Timestamp, Folgezettel}
+
+// Scheme generates and validates the IDs of one format.
+type Scheme interface {
+	// Name returns the name of the scheme in the configuration.
+	Name() string
+	// New returns a new ID for which taken returns false.
+	New(taken func(id string) bool) string
+	// Valid reports whether id has the format of the scheme.
+	Valid(id string) bool
+}
+
+/*
+New returns the scheme with the given name.
+
+Usage:
+
+	scheme, err := ids.New(config.App.IDScheme)
+
+Parameters:
+
+	name (string): the name of the scheme; empty selects uuid4
+
+Returns:
+
+	(Scheme): the scheme
+	(error): if there is no scheme with that name
+*/
+func New(name string) (Scheme, error) {
+	switch name {
+	case "", UUID4:
+		return uuid4Scheme{}, nil
+	case UUID7:
+		return uuid7Scheme{}, nil
+	case ULID:
+		return ulidScheme{}, nil
+	case Timestamp:
+		return TimestampScheme{Now: time.Now}, nil
+	case Folgezettel:
+		return FolgezettelScheme{}, nil
+	}
+	return nil, fmt.Errorf("unknown ID scheme %q, expected one of %s", name, strings.Join(Schemes, ", "))
+}
+
+// unique calls generate until it returns an ID that is not taken.
+func unique(generate func() string, taken func(id string) bool) string {
+	for {
+		if id := generate(); !taken(id) {
+			return id
+		}
+	}
+}
+
+type uuid4Scheme struct{}
+
+func (uuid4Scheme) Name() string { return UUID4 }
+
+func (uuid4Scheme) New(taken func(id string) bool) string {
+	return unique(func() string { return uuid.New().String() }, taken)
+}
+
+func (uuid4Scheme) Valid(id string) bool {
+	return validUUID(id, 4)
+}
+
+type uuid7Scheme struct{}
+
+func (uuid7Scheme) Name() string { return UUID7 }
+
+func (uuid7Scheme) New(taken func(id string) bool) string {
+	return unique(func() string { return uuid.Must(uuid.NewV7()).String() }, taken)
+}
+
+func (uuid7Scheme) Valid(id string) bool {
+	return validUUID(id, 7)
+}
+
+// validUUID reports whether id is a UUID of the given version in its canonical
+// 36-character form.
+func validUUID(id string, version uuid.Version) bool {
+	u, err := uuid.Parse(id)
+	return err == nil && len(id) == 36 && u.Version() == version && u.Variant() == uuid.RFC4122
+}
+
+type ulidScheme struct{}
+
+func (ulidScheme) Name() string { return ULID }
+
+func (ulidScheme) New(taken func(id string) bool) string {
+	return unique(func() string { return ulid.Make().String() }, taken)
+}
+
+func (ulidScheme) Valid(id string) bool {
+	_, err := ulid.ParseStrict(id)
+	return err == nil && strings.ToUpper(id) == id
+}
+
+// TimestampLayout is the format of timestamp IDs.
+const TimestampLayout = "200601021504"
+
+// TimestampScheme uses the minute of creation as the ID. When that minute is taken,
+// the following minutes are tried in turn.
+type TimestampScheme struct {
+	// Now returns the current time.
+	Now func() time.Time
+}
+
+func (TimestampScheme) Name() string { return Timestamp }
+
+func (s TimestampScheme) New(taken func(id string) bool) string {
+	t := s.Now()
+	for {
+		if id := t.Format(TimestampLayout); !taken(id) {
+			return id
+		}
+		t = t.Add(time.Minute)
+	}
+}
+
+func (TimestampScheme) Valid(id string) bool {
+	_, err := time.Parse(TimestampLayout, id)
+	return err == nil && len(id) == len(TimestampLayout)
+}
+
+// folgezettelRegex matches a Folgezettel: numbers and lowercase letters alternate,
+// starting with a number.
+var folgezettelRegex = regexp.MustCompile(`^[1-9][0-9]*(?:[a-z]+[1-9][0-9]*)*[a-z]*$`)
+
+// FolgezettelScheme numbers notes in Luhmann's branching sequence. New notes start a new
+// top-level sequence; Child continues or branches off an existing note.
+type FolgezettelScheme struct{}
+
+func (FolgezettelScheme) Name() string { return Folgezettel }
+
+// New returns the first top-level number that is not taken.
+func (FolgezettelScheme) New(taken func(id string) bool) string {
+	for n := 1; ; n++ {
+		if id := strconv.Itoa(n); !taken(id) {
+			return id
+		}
+	}
+}
+
+func (FolgezettelScheme) Valid(id string) bool {
+	return folgezettelRegex.MatchString(id)
+}
+
+/*
+Child returns the first free ID branching off parent. The children of a note ending in
+a number get letters (1 → 1a, 1b, ..., 1z, 1aa) and those of a note ending in a letter
+get numbers (1a → 1a1, 1a2).
+
+Usage:
+
+	id, err := ids.FolgezettelScheme{}.Child("1a", taken)
+
+Parameters:
+
+	parent (string): the ID of the parent note
+	taken (func(string) bool): reports whether an ID is in use
+
+Returns:
+
+	(string): the new ID
+	(error): if parent is not a Folgezettel
+*/
+func (s FolgezettelScheme) Child(parent string, taken func(id string) bool) (string, error) {
+	if !s.Valid(parent) {
+		return "", fmt.Errorf("%q is not a valid %s ID", parent, Folgezettel)
+	}
+	last := parent[len(parent)-1]
+	for n := 1; ; n++ {
+		var id string
+		if last >= '0' && last <= '9' {
+			id = parent + letters(n)
+		} else {
+			id = parent + strconv.Itoa(n)
+		}
+		if !taken(id) {
+			return id, nil
+		}
+	}
+}
+
+// letters returns the n-th letter sequence: a, b, ..., z, aa, ab, ...
+func letters(n int) string {
+	var b []byte
+	for ; n > 0; n = (n - 1) / 26 {
+		b = append([]byte{byte('a' + (n-1)%26)}, b...)
+	}
+	return string(b)
+}
+
+/*
+FilenamePrefix returns the ID at the start of a file name, such as 202610181230 in
+"202610181230 Limits.md". The ID must be valid for the scheme and be followed by a space,
+'-', '_' or the extension.
+
+Usage:
+
+	id := ids.FilenamePrefix(scheme, "/notes/202610181230 Limits.md")
+
+Parameters:
+
+	scheme (Scheme): the ID scheme
+	path (string): the path of the file
+
+Returns:
+
+	(string): the ID, or an empty string if the name does not start with one
+*/
+func FilenamePrefix(scheme Scheme, path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	// The longest candidate wins, so that the hyphens of a UUID are not taken as a separator.
+	for end := len(name); end > 0; end-- {
+		if end < len(name) && !strings.ContainsRune(" -_", rune(name[end])) {
+			continue
+		}
+		if candidate := name[:end]; scheme.Valid(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// WithFilenamePrefix returns the path with the ID put in front of the file name.
+func WithFilenamePrefix(path, id string) string {
+	return filepath.Join(filepath.Dir(path), id+" "+filepath.Base(path))
+}
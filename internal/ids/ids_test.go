@@ -0,0 +1,135 @@
+package ids_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ozcankasal/zettelo/internal/ids"
+)
+
+func none(string) bool { return false }
+
+func TestSchemesGenerateValidIDs(t *testing.T) {
+	for _, name := range ids.Schemes {
+		scheme, err := ids.New(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scheme.Name() != name {
+			t.Errorf("Expected scheme %s, got %s", name, scheme.Name())
+		}
+		id := scheme.New(none)
+		if !scheme.Valid(id) {
+			t.Errorf("%s: generated ID %q is not valid", name, id)
+		}
+	}
+
+	if _, err := ids.New("sequential"); err == nil {
+		t.Errorf("Expected an error for an unknown scheme")
+	}
+	if scheme, _ := ids.New(""); scheme.Name() != ids.UUID4 {
+		t.Errorf("Expected uuid4 by default, got %s", scheme.Name())
+	}
+}
+
+func TestValid(t *testing.T) {
+	testCases := []struct {
+		scheme string
+		id     string
+		valid  bool
+	}{
+		{ids.UUID4, "e9eb51f7-0706-4eb8-a343-6c0c7f4f6e4d", true},
+		{ids.UUID4, "01927b2e-7c4a-7d1e-9f3b-6a1c2d3e4f50", false},
+		{ids.UUID4, "{e9eb51f7-0706-4eb8-a343-6c0c7f4f6e4d}", false},
+		{ids.UUID7, "01927b2e-7c4a-7d1e-9f3b-6a1c2d3e4f50", true},
+		{ids.ULID, "01J9XQ3ZK8M4N6P7R8S9T0V1W2", true},
+		{ids.ULID, "01J9XQ3ZK8M4N6P7R8S9T0V1WU", false},
+		{ids.Timestamp, "202610181230", true},
+		{ids.Timestamp, "202613181230", false},
+		{ids.Timestamp, "2026101812", false},
+		{ids.Folgezettel, "1", true},
+		{ids.Folgezettel, "12a3b", true},
+		{ids.Folgezettel, "1a01", false},
+		{ids.Folgezettel, "a1", false},
+		{ids.Folgezettel, "0", false},
+	}
+
+	for _, tc := range testCases {
+		scheme, _ := ids.New(tc.scheme)
+		if valid := scheme.Valid(tc.id); valid != tc.valid {
+			t.Errorf("%s: expected Valid(%q) to be %v", tc.scheme, tc.id, tc.valid)
+		}
+	}
+}
+
+func TestTimestampCollisions(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 30, 45, 0, time.UTC)
+	scheme := ids.TimestampScheme{Now: func() time.Time { return now }}
+	taken := map[string]bool{"202610181230": true, "202610181231": true}
+
+	if id := scheme.New(func(id string) bool { return taken[id] }); id != "202610181232" {
+		t.Errorf("Expected the first free minute 202610181232, got %s", id)
+	}
+}
+
+func TestFolgezettel(t *testing.T) {
+	scheme := ids.FolgezettelScheme{}
+	taken := map[string]bool{"1": true, "2": true, "1a": true, "1a1": true}
+	isTaken := func(id string) bool { return taken[id] }
+
+	if id := scheme.New(isTaken); id != "3" {
+		t.Errorf("Expected 3, got %s", id)
+	}
+	testCases := []struct {
+		parent   string
+		expected string
+	}{
+		{"1", "1b"},
+		{"2", "2a"},
+		{"1a", "1a2"},
+		{"1a1", "1a1a"},
+	}
+	for _, tc := range testCases {
+		id, err := scheme.Child(tc.parent, isTaken)
+		if err != nil || id != tc.expected {
+			t.Errorf("Expected child %s of %s, got %s (%v)", tc.expected, tc.parent, id, err)
+		}
+	}
+
+	for c := 'a'; c <= 'z'; c++ {
+		taken["5"+string(c)] = true
+	}
+	if id, _ := scheme.Child("5", isTaken); id != "5aa" {
+		t.Errorf("Expected 5aa after 5z, got %s", id)
+	}
+	if _, err := scheme.Child("x", isTaken); err == nil {
+		t.Errorf("Expected an error for an invalid parent")
+	}
+}
+
+func TestFilenamePrefix(t *testing.T) {
+	testCases := []struct {
+		scheme   string
+		path     string
+		expected string
+	}{
+		{ids.Timestamp, "/notes/202610181230 Limits.md", "202610181230"},
+		{ids.Timestamp, "/notes/202610181230.md", "202610181230"},
+		{ids.Timestamp, "/notes/2026101812301 Limits.md", ""},
+		{ids.Timestamp, "/notes/Limits.md", ""},
+		{ids.UUID4, "e9eb51f7-0706-4eb8-a343-6c0c7f4f6e4d-limits.md", "e9eb51f7-0706-4eb8-a343-6c0c7f4f6e4d"},
+		{ids.Folgezettel, "1a2_limits.md", "1a2"},
+		{ids.Folgezettel, "1a2b.md", "1a2b"},
+	}
+	for _, tc := range testCases {
+		scheme, _ := ids.New(tc.scheme)
+		if id := ids.FilenamePrefix(scheme, tc.path); id != tc.expected {
+			t.Errorf("%s: expected prefix %q of %s, got %q", tc.scheme, tc.expected, tc.path, id)
+		}
+	}
+
+	if path := ids.WithFilenamePrefix(filepath.Join("notes", "Limits.md"), "1a"); path != filepath.Join("notes", "1a Limits.md") {
+		t.Errorf("Unexpected path %s", path)
+	}
+}
@@ -0,0 +1,202 @@
+package index
+
+import (
+	"sort"
+
+	"github.com/ozcankasal/zettelo/internal"
+)
+
+// DefaultHubs is the number of hubs listed unless another number is asked for.
+const DefaultHubs = 10
+
+// Analysis lists the notes needing attention. Paths are sorted unless stated otherwise.
+type Analysis struct {
+	// Orphans are the notes no other note links to.
+	Orphans []string `json:"orphans"`
+	// DeadEnds are the notes linking to no other note.
+	DeadEnds []string `json:"dead_ends"`
+	// Untagged are the notes without tags.
+	Untagged []string `json:"untagged"`
+	// Hubs are the notes linked with the most other notes, most connected first.
+	Hubs []Hub `json:"hubs"`
+	// Components are the groups of notes connected by links in either direction,
+	// largest first.
+	Components [][]string `json:"components"`
+	// Bridges are the notes whose removal splits their component.
+	Bridges []string `json:"bridges"`
+}
+
+// Hub is a note with the number of notes linking to it and linked from it.
+type Hub struct {
+	Path     string `json:"path"`
+	Incoming int    `json:"incoming"`
+	Outgoing int    `json:"outgoing"`
+	Degree   int    `json:"degree"`
+}
+
+/*
+Analyze finds orphans, dead ends, untagged notes, hubs, connected components and bridge
+notes. Only resolved links between different notes count.
+
+Usage:
+
+	analysis := index.Analyze(snapshot.Files, snapshot.Tags, snapshot.Links(), index.DefaultHubs)
+
+Parameters:
+
+	files (map[string]internal.Note): the notes by file path
+	tags (internal.TagList): the tag index of the notes
+	links (*LinkGraph): the resolved links of the notes
+	hubs (int): the maximum number of hubs to list
+
+Returns:
+
+	(*Analysis): the analysis
+*/
+func Analyze(files map[string]internal.Note, tags internal.TagList, links *LinkGraph, hubs int) *Analysis {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	outgoing := make(map[string]map[string]bool)
+	incoming := make(map[string]map[string]bool)
+	for source, resolved := range links.Links {
+		for _, link := range resolved {
+			if _, ok := files[link.Path]; !ok || link.Status != LinkResolved || link.Path == source {
+				continue
+			}
+			if outgoing[source] == nil {
+				outgoing[source] = make(map[string]bool)
+			}
+			if incoming[link.Path] == nil {
+				incoming[link.Path] = make(map[string]bool)
+			}
+			outgoing[source][link.Path] = true
+			incoming[link.Path][source] = true
+		}
+	}
+
+	tagged := make(map[string]bool)
+	for _, tag := range tags {
+		for _, value := range tag.Values {
+			tagged[value.FilePath] = true
+		}
+	}
+
+	analysis := &Analysis{Orphans: []string{}, DeadEnds: []string{}, Untagged: []string{}, Hubs: []Hub{}}
+	for _, path := range paths {
+		if len(incoming[path]) == 0 {
+			analysis.Orphans = append(analysis.Orphans, path)
+		}
+		if len(outgoing[path]) == 0 {
+			analysis.DeadEnds = append(analysis.DeadEnds, path)
+		}
+		if !tagged[path] {
+			analysis.Untagged = append(analysis.Untagged, path)
+		}
+		if degree := len(incoming[path]) + len(outgoing[path]); degree > 0 {
+			analysis.Hubs = append(analysis.Hubs, Hub{Path: path, Incoming: len(incoming[path]), Outgoing: len(outgoing[path]), Degree: degree})
+		}
+	}
+	sort.SliceStable(analysis.Hubs, func(i, j int) bool { return analysis.Hubs[i].Degree > analysis.Hubs[j].Degree })
+	if len(analysis.Hubs) > hubs {
+		analysis.Hubs = analysis.Hubs[:hubs]
+	}
+
+	// Components and bridges ignore the direction of links.
+	neighbours := make(map[string][]string, len(paths))
+	for _, path := range paths {
+		seen := make(map[string]bool)
+		for _, m := range []map[string]bool{outgoing[path], incoming[path]} {
+			for other := range m {
+				if !seen[other] {
+					seen[other] = true
+					neighbours[path] = append(neighbours[path], other)
+				}
+			}
+		}
+		sort.Strings(neighbours[path])
+	}
+	analysis.Components = components(paths, neighbours)
+	analysis.Bridges = articulationPoints(paths, neighbours)
+	return analysis
+}
+
+// components returns the connected components of an undirected graph, largest first.
+func components(paths []string, neighbours map[string][]string) [][]string {
+	result := [][]string{}
+	visited := make(map[string]bool)
+	for _, path := range paths {
+		if visited[path] {
+			continue
+		}
+		visited[path] = true
+		component := []string{}
+		for queue := []string{path}; len(queue) > 0; queue = queue[1:] {
+			component = append(component, queue[0])
+			for _, other := range neighbours[queue[0]] {
+				if !visited[other] {
+					visited[other] = true
+					queue = append(queue, other)
+				}
+			}
+		}
+		sort.Strings(component)
+		result = append(result, component)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return len(result[i]) > len(result[j]) })
+	return result
+}
+
+// articulationPoints returns the nodes of an undirected graph whose removal disconnects
+// their component, using Tarjan's algorithm.
+func articulationPoints(paths []string, neighbours map[string][]string) []string {
+	discovered := make(map[string]int)
+	low := make(map[string]int)
+	points := make(map[string]bool)
+	clock := 0
+
+	var visit func(node, parent string)
+	visit = func(node, parent string) {
+		clock++
+		discovered[node], low[node] = clock, clock
+		children := 0
+		for _, other := range neighbours[node] {
+			if other == parent {
+				continue
+			}
+			if discovered[other] > 0 {
+				if discovered[other] < low[node] {
+					low[node] = discovered[other]
+				}
+				continue
+			}
+			children++
+			visit(other, node)
+			if low[other] < low[node] {
+				low[node] = low[other]
+			}
+			if parent != "" && low[other] >= discovered[node] {
+				points[node] = true
+			}
+		}
+		if parent == "" && children > 1 {
+			points[node] = true
+		}
+	}
+	for _, path := range paths {
+		if discovered[path] == 0 {
+			visit(path, "")
+		}
+	}
+
+	result := []string{}
+	for _, path := range paths {
+		if points[path] {
+			result = append(result, path)
+		}
+	}
+	return result
+}
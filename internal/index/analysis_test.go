@@ -0,0 +1,59 @@
+package index_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/index"
+)
+
+func TestAnalyze(t *testing.T) {
+	wiki := func(targets ...string) []internal.Link {
+		var links []internal.Link
+		for _, target := range targets {
+			links = append(links, internal.Link{Kind: internal.LinkWiki, Target: target})
+		}
+		return links
+	}
+	// a <-> b -> c -> d, with e alone; c also links to itself and to a missing note.
+	files := map[string]internal.Note{
+		"a.md": {Links: wiki("b")},
+		"b.md": {Links: wiki("a", "c", "c")},
+		"c.md": {Links: wiki("d", "c", "missing")},
+		"d.md": {},
+		"e.md": {},
+	}
+	tags := internal.TagList{
+		{Tag: "#idea", Values: []internal.ResultValue{{FilePath: "a.md"}, {FilePath: "c.md"}}},
+		{Tag: "#math", Values: []internal.ResultValue{{FilePath: "c.md"}}},
+	}
+
+	analysis := index.Analyze(files, tags, index.BuildLinkGraph(files), 2)
+	expected := &index.Analysis{
+		Orphans:  []string{"e.md"},
+		DeadEnds: []string{"d.md", "e.md"},
+		Untagged: []string{"b.md", "d.md", "e.md"},
+		Hubs: []index.Hub{
+			{Path: "b.md", Incoming: 1, Outgoing: 2, Degree: 3},
+			{Path: "a.md", Incoming: 1, Outgoing: 1, Degree: 2},
+		},
+		Components: [][]string{{"a.md", "b.md", "c.md", "d.md"}, {"e.md"}},
+		Bridges:    []string{"b.md", "c.md"},
+	}
+	if !reflect.DeepEqual(analysis, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, analysis)
+	}
+}
+
+func TestAnalyzeCycleHasNoBridges(t *testing.T) {
+	files := map[string]internal.Note{
+		"a.md": {Links: []internal.Link{{Kind: internal.LinkWiki, Target: "b"}}},
+		"b.md": {Links: []internal.Link{{Kind: internal.LinkWiki, Target: "c"}}},
+		"c.md": {Links: []internal.Link{{Kind: internal.LinkWiki, Target: "a"}}},
+	}
+	analysis := index.Analyze(files, nil, index.BuildLinkGraph(files), index.DefaultHubs)
+	if len(analysis.Bridges) != 0 || len(analysis.Components) != 1 || len(analysis.Orphans) != 0 || len(analysis.DeadEnds) != 0 {
+		t.Errorf("Expected one component without bridges, orphans or dead ends, got %+v", analysis)
+	}
+}
@@ -0,0 +1,254 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ozcankasal/zettelo/internal"
+)
+
+// Co-occurrence scopes: tags co-occur when they are used on the same line, in the same
+// section or in the same note.
+const (
+	ScopeLine    = "line"
+	ScopeSection = "section"
+	ScopeNote    = "note"
+)
+
+// Scopes lists the co-occurrence scopes.
+var Scopes = []string{ScopeLine, ScopeSection, ScopeNote}
+
+// Scores of related tags.
+const (
+	// MetricPMI is the pointwise mutual information, log2 of how much more often two tags
+	// appear together than they would by chance. It is 0 for unrelated tags.
+	MetricPMI = "pmi"
+	// MetricJaccard is the share of the units using either tag that use both, from 0 to 1.
+	MetricJaccard = "jaccard"
+)
+
+// Metrics lists the scores of related tags.
+var Metrics = []string{MetricPMI, MetricJaccard}
+
+// Cooccurrence counts how often tags are used together in one scope.
+type Cooccurrence struct {
+	Scope string
+	// Units is the number of lines, sections or notes using at least one tag.
+	Units int
+	// Counts maps every tag to the number of units using it.
+	Counts map[string]int
+	// pairs maps every pair of tags, in sorted order, to the number of units using both.
+	pairs map[[2]string]int
+}
+
+/*
+BuildCooccurrence counts the lines, sections or notes using every tag and every pair of
+tags. A tag used twice in one unit counts once. Lines are told apart by their line
+number and sections by their heading path. Tags without a location and the tags of the
+front matter, which all share one line, only count in the note scope.
+
+Usage:
+
+	cooccurrence, err := index.BuildCooccurrence(snapshot.Files, index.ScopeLine)
+	fmt.Println(cooccurrence.Together("#idea", "#insight"))
+
+Parameters:
+
+	files (map[string]internal.Note): the notes by file path
+	scope (string): one of Scopes
+
+Returns:
+
+	(*Cooccurrence): the counts
+	(error): if the scope is unknown
+*/
+func BuildCooccurrence(files map[string]internal.Note, scope string) (*Cooccurrence, error) {
+	if !contains(Scopes, scope) {
+		return nil, fmt.Errorf("unknown scope %q, expected one of %s", scope, strings.Join(Scopes, ", "))
+	}
+
+	units := make(map[string]map[string]bool)
+	add := func(unit, tag string) {
+		if units[unit] == nil {
+			units[unit] = make(map[string]bool)
+		}
+		units[unit][tag] = true
+	}
+	for path, note := range files {
+		for _, line := range note.Lines {
+			if scope == ScopeNote || len(line.Values) == 0 {
+				if scope == ScopeNote {
+					add(path, line.Tag)
+				}
+				continue
+			}
+			for _, value := range line.Values {
+				switch {
+				case value.Origin == internal.OriginFrontMatter:
+				case scope == ScopeSection:
+					add(path+"\x00"+value.Heading, line.Tag)
+				case value.LineNumber > 0:
+					add(path+"\x00"+strconv.Itoa(value.LineNumber), line.Tag)
+				}
+			}
+		}
+	}
+
+	c := &Cooccurrence{Scope: scope, Units: len(units), Counts: make(map[string]int), pairs: countPairs(units)}
+	for _, tagSet := range units {
+		for tag := range tagSet {
+			c.Counts[tag]++
+		}
+	}
+	return c, nil
+}
+
+// Together returns the number of units using both tags.
+func (c *Cooccurrence) Together(a, b string) int {
+	if a > b {
+		a, b = b, a
+	}
+	return c.pairs[[2]string{a, b}]
+}
+
+// Score returns the PMI or Jaccard score of two tags; see MetricPMI and MetricJaccard.
+// Tags that are never used together score 0.
+func (c *Cooccurrence) Score(a, b, metric string) float64 {
+	together := c.Together(a, b)
+	if together == 0 {
+		return 0
+	}
+	if metric == MetricPMI {
+		return math.Log2(float64(together) * float64(c.Units) / (float64(c.Counts[a]) * float64(c.Counts[b])))
+	}
+	return float64(together) / float64(c.Counts[a]+c.Counts[b]-together)
+}
+
+// RelatedTag is a tag used together with another one.
+type RelatedTag struct {
+	Tag string `json:"tag"`
+	// Together is the number of units using both tags, Count those using this one.
+	Together int     `json:"together"`
+	Count    int     `json:"count"`
+	Score    float64 `json:"score"`
+	// Synonym marks a probable synonym, see SynonymCandidates.
+	Synonym bool `json:"synonym"`
+}
+
+/*
+Related returns the tags used together with a tag, best scoring first.
+
+Usage:
+
+	related, err := cooccurrence.Related("#idea", index.MetricJaccard)
+
+Parameters:
+
+	tag (string): the tag
+	metric (string): one of Metrics
+
+Returns:
+
+	([]RelatedTag): the related tags, ordered by score, then by the number of units
+	using both tags and then by tag
+	(error): if the metric is unknown
+*/
+func (c *Cooccurrence) Related(tag, metric string) ([]RelatedTag, error) {
+	if !contains(Metrics, metric) {
+		return nil, fmt.Errorf("unknown metric %q, expected one of %s", metric, strings.Join(Metrics, ", "))
+	}
+	related := []RelatedTag{}
+	for pair, together := range c.pairs {
+		other := ""
+		switch tag {
+		case pair[0]:
+			other = pair[1]
+		case pair[1]:
+			other = pair[0]
+		default:
+			continue
+		}
+		related = append(related, RelatedTag{
+			Tag:      other,
+			Together: together,
+			Count:    c.Counts[other],
+			Score:    c.Score(tag, other, metric),
+			Synonym:  c.synonym(tag, other),
+		})
+	}
+	sort.Slice(related, func(i, j int) bool {
+		a, b := related[i], related[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Together != b.Together {
+			return a.Together > b.Together
+		}
+		return a.Tag < b.Tag
+	})
+	return related, nil
+}
+
+// Thresholds above which two tags are taken for synonyms.
+const (
+	// SynonymJaccard is the minimum Jaccard score of synonyms.
+	SynonymJaccard = 0.5
+	// SynonymTogether is the minimum number of units using both synonyms.
+	SynonymTogether = 2
+)
+
+// SynonymCandidate is a tag that could be mapped to another one with tag_mappings.
+type SynonymCandidate struct {
+	Tag       string  `json:"tag"`
+	Canonical string  `json:"canonical"`
+	Together  int     `json:"together"`
+	Jaccard   float64 `json:"jaccard"`
+}
+
+// synonym reports whether two tags are mostly used together. A tag and the tags nested
+// below it are never synonyms.
+func (c *Cooccurrence) synonym(a, b string) bool {
+	if IsDescendant(a, b) || IsDescendant(b, a) {
+		return false
+	}
+	return c.Together(a, b) >= SynonymTogether && c.Score(a, b, MetricJaccard) >= SynonymJaccard
+}
+
+/*
+SynonymCandidates returns the pairs of tags that are mostly used together: at least
+SynonymTogether units use both and their Jaccard score is at least SynonymJaccard. The
+less used tag of each pair is proposed to be mapped to the other one.
+
+Usage:
+
+	for _, candidate := range cooccurrence.SynonymCandidates() {
+		fmt.Printf("%q: %q\n", candidate.Tag, candidate.Canonical)
+	}
+
+Returns:
+
+	([]SynonymCandidate): the candidates, ordered by Jaccard score and then by tag
+*/
+func (c *Cooccurrence) SynonymCandidates() []SynonymCandidate {
+	candidates := []SynonymCandidate{}
+	for pair, together := range c.pairs {
+		if !c.synonym(pair[0], pair[1]) {
+			continue
+		}
+		tag, canonical := pair[0], pair[1]
+		if c.Counts[tag] > c.Counts[canonical] || c.Counts[tag] == c.Counts[canonical] && tag < canonical {
+			tag, canonical = canonical, tag
+		}
+		candidates = append(candidates, SynonymCandidate{Tag: tag, Canonical: canonical, Together: together, Jaccard: c.Score(tag, canonical, MetricJaccard)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Jaccard != candidates[j].Jaccard {
+			return candidates[i].Jaccard > candidates[j].Jaccard
+		}
+		return candidates[i].Tag < candidates[j].Tag
+	})
+	return candidates
+}
@@ -0,0 +1,163 @@
+package index_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/index"
+)
+
+// cooccurrenceNotes uses #idea and #insight on the same lines, #math in the same
+// sections and #todo in the same notes.
+func cooccurrenceNotes() map[string]internal.Note {
+	value := func(line int, heading string) internal.ResultValue {
+		return internal.ResultValue{LineNumber: line, Heading: heading}
+	}
+	return map[string]internal.Note{
+		"a.md": {Lines: internal.TagList{
+			{Tag: "#idea", Values: []internal.ResultValue{value(1, "A"), value(5, "B")}},
+			{Tag: "#insight", Values: []internal.ResultValue{value(1, "A"), value(5, "B")}},
+			{Tag: "#math", Values: []internal.ResultValue{value(2, "A")}},
+			{Tag: "#todo", Values: []internal.ResultValue{value(9, "C")}},
+		}},
+		"b.md": {Lines: internal.TagList{
+			{Tag: "#idea", Values: []internal.ResultValue{value(3, "")}},
+			{Tag: "#insight", Values: []internal.ResultValue{value(3, "")}},
+			{Tag: "#math", Values: []internal.ResultValue{value(4, "")}},
+		}},
+		"c.md": {Lines: internal.TagList{
+			{Tag: "#todo", Values: []internal.ResultValue{value(1, "")}},
+			{Tag: "#math/calculus"},
+		}},
+	}
+}
+
+func TestBuildCooccurrence(t *testing.T) {
+	files := cooccurrenceNotes()
+	tests := []struct {
+		scope        string
+		units        int
+		ideaInsight  int
+		ideaMath     int
+		ideaTodo     int
+		mathCalculus int
+	}{
+		{index.ScopeLine, 7, 3, 0, 0, 0},
+		{index.ScopeSection, 5, 3, 2, 0, 0},
+		{index.ScopeNote, 3, 2, 2, 1, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.scope, func(t *testing.T) {
+			c, err := index.BuildCooccurrence(files, test.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []int{c.Units, c.Together("#idea", "#insight"), c.Together("#math", "#idea"), c.Together("#idea", "#todo"), c.Together("#math", "#math/calculus")}
+			expected := []int{test.units, test.ideaInsight, test.ideaMath, test.ideaTodo, test.mathCalculus}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected units and pair counts %v, got %v", expected, got)
+			}
+		})
+	}
+
+	if _, err := index.BuildCooccurrence(files, "paragraph"); err == nil {
+		t.Error("Expected an error for an unknown scope")
+	}
+}
+
+func TestBuildCooccurrenceFrontMatter(t *testing.T) {
+	// The tags of a header all share the line of the title, yet are not used together
+	// on one line or in one section.
+	frontMatter := func(tag string) internal.TaggedLine {
+		return internal.TaggedLine{Tag: tag, Values: []internal.ResultValue{{LineNumber: 1, Origin: internal.OriginFrontMatter}}}
+	}
+	files := map[string]internal.Note{
+		"a.md": {Lines: internal.TagList{frontMatter("#idea"), frontMatter("#math")}},
+		"b.md": {Lines: internal.TagList{
+			frontMatter("#idea"),
+			frontMatter("#math"),
+			{Tag: "#math", Values: []internal.ResultValue{{LineNumber: 4, Origin: internal.OriginHashtag}}},
+		}},
+	}
+	tests := []struct {
+		scope    string
+		units    int
+		together int
+	}{
+		{index.ScopeLine, 1, 0},
+		{index.ScopeSection, 1, 0},
+		{index.ScopeNote, 2, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.scope, func(t *testing.T) {
+			c, err := index.BuildCooccurrence(files, test.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Units != test.units || c.Together("#idea", "#math") != test.together {
+				t.Errorf("Expected %d units and %d together, got %d and %d", test.units, test.together, c.Units, c.Together("#idea", "#math"))
+			}
+			if candidates := c.SynonymCandidates(); test.scope != index.ScopeNote && len(candidates) != 0 {
+				t.Errorf("Expected no synonyms from front matter tags, got %+v", candidates)
+			}
+		})
+	}
+}
+
+func TestRelated(t *testing.T) {
+	c, err := index.BuildCooccurrence(cooccurrenceNotes(), index.ScopeSection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	related, err := c.Related("#idea", index.MetricJaccard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []index.RelatedTag{
+		{Tag: "#insight", Together: 3, Count: 3, Score: 1, Synonym: true},
+		{Tag: "#math", Together: 2, Count: 2, Score: 2.0 / 3, Synonym: true},
+	}
+	if !reflect.DeepEqual(related, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, related)
+	}
+
+	related, err = c.Related("#idea", index.MetricPMI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 5 sections: #idea and #insight are in 3 each and always together.
+	if len(related) != 2 || math.Abs(related[0].Score-math.Log2(5.0/3)) > 1e-9 {
+		t.Errorf("Expected a PMI of log2(5/3) for #insight, got %+v", related)
+	}
+
+	if _, err := c.Related("#idea", "cosine"); err == nil {
+		t.Error("Expected an error for an unknown metric")
+	}
+}
+
+func TestSynonymCandidates(t *testing.T) {
+	files := cooccurrenceNotes()
+	c, err := index.BuildCooccurrence(files, index.ScopeLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []index.SynonymCandidate{{Tag: "#insight", Canonical: "#idea", Together: 3, Jaccard: 1}}
+	if candidates := c.SynonymCandidates(); !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, candidates)
+	}
+
+	// Nested tags are never synonyms of their parent.
+	files["d.md"] = internal.Note{Lines: internal.TagList{
+		{Tag: "#math", Values: []internal.ResultValue{{LineNumber: 1}, {LineNumber: 2}}},
+		{Tag: "#math/calculus", Values: []internal.ResultValue{{LineNumber: 1}, {LineNumber: 2}}},
+	}}
+	if c, err = index.BuildCooccurrence(files, index.ScopeLine); err != nil {
+		t.Fatal(err)
+	}
+	if candidates := c.SynonymCandidates(); !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, candidates)
+	}
+}
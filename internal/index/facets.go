@@ -0,0 +1,184 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ozcankasal/zettelo/internal"
+)
+
+// FacetNames are the front matter fields that notes are grouped by.
+var FacetNames = []string{"type", "project"}
+
+// Facet groups the notes by the value of one front matter field.
+type Facet struct {
+	Name   string       `json:"name"`
+	Values []FacetValue `json:"values"`
+}
+
+// FacetValue is one value of a facet and the notes that have it.
+type FacetValue struct {
+	Value string `json:"value"`
+	// Count is the number of notes, listed in Paths sorted by path.
+	Count int      `json:"count"`
+	Paths []string `json:"paths"`
+}
+
+// facetValue returns the value of a facet for a note.
+func facetValue(note internal.Note, name string) string {
+	switch name {
+	case "type":
+		return note.Type
+	case "project":
+		return note.Project
+	}
+	return ""
+}
+
+/*
+BuildFacets counts the notes for every value of the facets in FacetNames. Notes without
+a value are not counted.
+
+Usage:
+
+	facets := index.BuildFacets(snapshot.Files)
+
+Parameters:
+
+	files (map[string]internal.Note): the notes by file path
+
+Returns:
+
+	([]Facet): one facet per name in FacetNames, with the values ordered by count and then by value
+*/
+func BuildFacets(files map[string]internal.Note) []Facet {
+	facets := make([]Facet, 0, len(FacetNames))
+	for _, name := range FacetNames {
+		paths := make(map[string][]string)
+		for path, note := range files {
+			if value := facetValue(note, name); value != "" {
+				paths[value] = append(paths[value], path)
+			}
+		}
+
+		values := make([]FacetValue, 0, len(paths))
+		for value, notes := range paths {
+			sort.Strings(notes)
+			values = append(values, FacetValue{Value: value, Count: len(notes), Paths: notes})
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+		facets = append(facets, Facet{Name: name, Values: values})
+	}
+	return facets
+}
+
+// FacetFilter maps facet names to the values a note may have. A note matches when it
+// has one of the values of every facet in the filter.
+type FacetFilter map[string][]string
+
+/*
+ParseFacetFilter parses a comma-separated list of facet=value terms, such as
+"type=concept, project=maths-book-writing". Terms naming the same facet are alternatives.
+
+Usage:
+
+	filter, err := index.ParseFacetFilter("type=concept, project=maths-book-writing")
+
+Parameters:
+
+	expr (string): the filter expression; an empty expression matches every note
+
+Returns:
+
+	(FacetFilter): the parsed filter
+	(error): if a term is not of the form facet=value or names an unknown facet
+*/
+func ParseFacetFilter(expr string) (FacetFilter, error) {
+	filter := FacetFilter{}
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(term, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid facet filter %q, expected facet=value", term)
+		}
+		if !isFacet(name) {
+			return nil, fmt.Errorf("unknown facet %q in %q, expected one of %s", name, term, strings.Join(FacetNames, ", "))
+		}
+		filter[name] = append(filter[name], value)
+	}
+	return filter, nil
+}
+
+func isFacet(name string) bool {
+	for _, facet := range FacetNames {
+		if facet == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the note has one of the values of every facet in the filter.
+func (f FacetFilter) Match(note internal.Note) bool {
+	for name, values := range f {
+		value := facetValue(note, name)
+		found := false
+		for _, v := range values {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+FilterFacets returns the entries of the tag list that belong to the notes matching the
+filter. Tags left without entries are dropped.
+
+Usage:
+
+	tagList := index.FilterFacets(snapshot.Tags, snapshot.Files, filter)
+
+Parameters:
+
+	tags (internal.TagList): the tag list
+	files (map[string]internal.Note): the notes by file path
+	filter (FacetFilter): the filter to apply
+
+Returns:
+
+	(internal.TagList): the selected entries, in the order of tags
+*/
+func FilterFacets(tags internal.TagList, files map[string]internal.Note, filter FacetFilter) internal.TagList {
+	if len(filter) == 0 {
+		return tags
+	}
+	result := internal.TagList{}
+	for _, tagged := range tags {
+		var values []internal.ResultValue
+		for _, value := range tagged.Values {
+			if filter.Match(files[value.FilePath]) {
+				values = append(values, value)
+			}
+		}
+		if len(values) > 0 {
+			result = append(result, internal.TaggedLine{Tag: tagged.Tag, Values: values})
+		}
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package index_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/index"
+)
+
+func TestBuildFacets(t *testing.T) {
+	files := map[string]internal.Note{
+		"a.md": {Path: "a.md", Type: "concept", Project: "maths-book-writing"},
+		"b.md": {Path: "b.md", Type: "concept"},
+		"c.md": {Path: "c.md", Type: "reference", Project: "maths-book-writing"},
+		"d.md": {Path: "d.md"},
+	}
+
+	expected := []index.Facet{
+		{Name: "type", Values: []index.FacetValue{
+			{Value: "concept", Count: 2, Paths: []string{"a.md", "b.md"}},
+			{Value: "reference", Count: 1, Paths: []string{"c.md"}},
+		}},
+		{Name: "project", Values: []index.FacetValue{
+			{Value: "maths-book-writing", Count: 2, Paths: []string{"a.md", "c.md"}},
+		}},
+	}
+	if facets := index.BuildFacets(files); !reflect.DeepEqual(facets, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, facets)
+	}
+}
+
+func TestParseFacetFilter(t *testing.T) {
+	filter, err := index.ParseFacetFilter("type=concept, project = maths-book-writing,type=idea")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := index.FacetFilter{"type": {"concept", "idea"}, "project": {"maths-book-writing"}}
+	if !reflect.DeepEqual(filter, expected) {
+		t.Errorf("Expected %v, got %v", expected, filter)
+	}
+
+	for _, expr := range []string{"type", "type=", "colour=red"} {
+		if _, err := index.ParseFacetFilter(expr); err == nil {
+			t.Errorf("Expected an error for %q", expr)
+		}
+	}
+	if filter, err := index.ParseFacetFilter(" "); err != nil || len(filter) != 0 {
+		t.Errorf("Expected an empty filter, got %v (%v)", filter, err)
+	}
+}
+
+func TestFilterFacets(t *testing.T) {
+	files := map[string]internal.Note{
+		"a.md": {Path: "a.md", Type: "concept", Project: "maths-book-writing"},
+		"b.md": {Path: "b.md", Type: "concept"},
+		"c.md": {Path: "c.md", Type: "idea", Project: "maths-book-writing"},
+	}
+	tags := internal.TagList{
+		{Tag: "#idea", Values: []internal.ResultValue{{FilePath: "b.md"}, {FilePath: "c.md"}}},
+		{Tag: "#todo", Values: []internal.ResultValue{{FilePath: "a.md"}, {FilePath: "b.md"}}},
+	}
+
+	filter, _ := index.ParseFacetFilter("type=concept, project=maths-book-writing")
+	expected := internal.TagList{
+		{Tag: "#todo", Values: []internal.ResultValue{{FilePath: "a.md"}}},
+	}
+	if got := index.FilterFacets(tags, files, filter); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	filter, _ = index.ParseFacetFilter("type=concept, type=idea")
+	if got := index.FilterFacets(tags, files, filter); !reflect.DeepEqual(got, tags) {
+		t.Errorf("Expected alternatives to select every entry, got %v", got)
+	}
+}
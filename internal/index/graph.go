@@ -0,0 +1,468 @@
+package index
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ozcankasal/zettelo/internal"
+)
+
+// Node kinds. Project and type nodes are named after their facet.
+const (
+	NodeNote    = "note"
+	NodeTag     = "tag"
+	NodeProject = "project"
+	NodeType    = "type"
+)
+
+// Edge kinds.
+const (
+	// EdgeLink goes from a note to a note it links to; the weight counts the links.
+	EdgeLink = "link"
+	// EdgeTag goes from a note to a tag it uses; the weight counts the tagged lines.
+	EdgeTag = "tag"
+	// EdgeCooccurrence joins two tags used in the same notes; the weight counts the notes.
+	EdgeCooccurrence = "cooccurrence"
+	// EdgeSharedProject joins two notes of the same project.
+	EdgeSharedProject = "shared_project"
+	// EdgeFacet goes from a note to its project or type node.
+	EdgeFacet = "facet"
+)
+
+// EdgeKinds lists the kinds of edges in the order they are written.
+var EdgeKinds = []string{EdgeLink, EdgeTag, EdgeCooccurrence, EdgeSharedProject, EdgeFacet}
+
+// GraphNode is a note, a tag or a facet value.
+type GraphNode struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+	// Path is the file of a note.
+	Path string `json:"path,omitempty"`
+	// Count is the number of notes using a tag or having a facet value.
+	Count int `json:"count,omitempty"`
+}
+
+// GraphEdge joins two nodes. Co-occurrence and shared project edges are undirected.
+type GraphEdge struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Kind     string `json:"kind"`
+	Weight   int    `json:"weight"`
+	Directed bool   `json:"directed"`
+}
+
+// Graph is the knowledge base as nodes and weighted edges.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"links"`
+}
+
+// GraphOptions selects the parts of the graph to build.
+type GraphOptions struct {
+	// Nodes lists the kinds of nodes besides notes, out of tag, project and type.
+	Nodes []string
+	// Edges lists the kinds of edges; all kinds when empty. Edges whose nodes are left
+	// out are never added.
+	Edges []string
+}
+
+/*
+ParseGraphOptions reads comma-separated lists of node and edge kinds.
+
+Usage:
+
+	opts, err := index.ParseGraphOptions("tag,project", "")
+
+Parameters:
+
+	nodes (string): the kinds of nodes besides notes, such as "tag,project"
+	edges (string): the kinds of edges, or an empty string for all of them
+
+Returns:
+
+	(GraphOptions): the options
+	(error): if a kind is unknown
+*/
+func ParseGraphOptions(nodes, edges string) (GraphOptions, error) {
+	var opts GraphOptions
+	var err error
+	if opts.Nodes, err = splitKinds(nodes, []string{NodeTag, NodeProject, NodeType}, "node"); err != nil {
+		return opts, err
+	}
+	opts.Edges, err = splitKinds(edges, EdgeKinds, "edge")
+	return opts, err
+}
+
+func splitKinds(list string, known []string, what string) ([]string, error) {
+	var kinds []string
+	for _, kind := range strings.Split(list, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		if !contains(known, kind) {
+			return nil, fmt.Errorf("unknown %s kind %q, expected one of %s", what, kind, strings.Join(known, ", "))
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NoteNodeID returns the ID of the node of a note.
+func NoteNodeID(path string) string {
+	return NodeNote + ":" + path
+}
+
+/*
+BuildGraph turns notes into a graph of notes, tags and, optionally, project and type
+values. Tags are grouped per note like the tag index, see groupTaggedLines, and the links
+are taken from the link graph. Nodes are ordered by kind and name, edges by kind,
+source and target.
+
+Usage:
+
+	graph := index.BuildGraph(snapshot.Files, snapshot.Links(), index.GraphOptions{Nodes: []string{index.NodeTag}})
+
+Parameters:
+
+	files (map[string]internal.Note): the notes by file path
+	links (*LinkGraph): the resolved links of the notes
+	opts (GraphOptions): the kinds of nodes and edges to include
+
+Returns:
+
+	(*Graph): the graph
+*/
+func BuildGraph(files map[string]internal.Note, links *LinkGraph, opts GraphOptions) *Graph {
+	graph := &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	hasEdge := func(kind string) bool { return len(opts.Edges) == 0 || contains(opts.Edges, kind) }
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		graph.Nodes = append(graph.Nodes, GraphNode{ID: NoteNodeID(path), Kind: NodeNote, Label: noteLabel(path, files[path]), Path: path})
+	}
+
+	if contains(opts.Nodes, NodeTag) {
+		addTags(graph, groupTaggedLines(files), hasEdge)
+	}
+	for _, facet := range FacetNames {
+		if contains(opts.Nodes, facet) {
+			addFacet(graph, files, paths, facet, hasEdge(EdgeFacet))
+		}
+	}
+
+	if hasEdge(EdgeLink) && links != nil {
+		// The edges are sorted once the graph is complete.
+		for _, source := range paths {
+			counts := make(map[string]int)
+			for _, link := range links.Links[source] {
+				if _, ok := files[link.Path]; ok && link.Status == LinkResolved && link.Path != source {
+					counts[link.Path]++
+				}
+			}
+			for target, count := range counts {
+				graph.Edges = append(graph.Edges, GraphEdge{Source: NoteNodeID(source), Target: NoteNodeID(target), Kind: EdgeLink, Weight: count, Directed: true})
+			}
+		}
+	}
+
+	if hasEdge(EdgeSharedProject) {
+		byProject := make(map[string][]string)
+		for _, path := range paths {
+			if project := files[path].Project; project != "" {
+				byProject[project] = append(byProject[project], path)
+			}
+		}
+		for _, members := range byProject {
+			for i := range members {
+				for _, other := range members[i+1:] {
+					graph.Edges = append(graph.Edges, GraphEdge{Source: NoteNodeID(members[i]), Target: NoteNodeID(other), Kind: EdgeSharedProject, Weight: 1})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.Kind != b.Kind {
+			return kindIndex(a.Kind) < kindIndex(b.Kind)
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Target < b.Target
+	})
+	return graph
+}
+
+// addTags adds a node for every tag with edges from the notes using it and between tags
+// used in the same notes.
+func addTags(graph *Graph, grouped map[string]map[string][]internal.ResultValue, hasEdge func(string) bool) {
+	tags := make([]string, 0, len(grouped))
+	for tag := range grouped {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		graph.Nodes = append(graph.Nodes, GraphNode{ID: NodeTag + ":" + tag, Kind: NodeTag, Label: tag, Count: len(grouped[tag])})
+		if hasEdge(EdgeTag) {
+			for path := range grouped[tag] {
+				graph.Edges = append(graph.Edges, GraphEdge{Source: NoteNodeID(path), Target: NodeTag + ":" + tag, Kind: EdgeTag, Weight: len(grouped[tag][path]), Directed: true})
+			}
+		}
+	}
+	if !hasEdge(EdgeCooccurrence) {
+		return
+	}
+	notes := make(map[string]map[string]bool)
+	for tag, paths := range grouped {
+		for path := range paths {
+			if notes[path] == nil {
+				notes[path] = make(map[string]bool)
+			}
+			notes[path][tag] = true
+		}
+	}
+	// The edges are sorted once the graph is complete.
+	for pair, shared := range countPairs(notes) {
+		graph.Edges = append(graph.Edges, GraphEdge{Source: NodeTag + ":" + pair[0], Target: NodeTag + ":" + pair[1], Kind: EdgeCooccurrence, Weight: shared})
+	}
+}
+
+// countPairs maps every pair of tags, in sorted order, to the number of units using both;
+// units maps every unit to the set of its tags. Only the pairs within each unit are
+// visited, so the cost follows the number of tags per unit rather than of all tags.
+func countPairs(units map[string]map[string]bool) map[[2]string]int {
+	pairs := make(map[[2]string]int)
+	for _, tagSet := range units {
+		tags := make([]string, 0, len(tagSet))
+		for tag := range tagSet {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		for i := range tags {
+			for _, other := range tags[i+1:] {
+				pairs[[2]string{tags[i], other}]++
+			}
+		}
+	}
+	return pairs
+}
+
+// addFacet adds a node for every value of a facet, with edges from the notes having it.
+func addFacet(graph *Graph, files map[string]internal.Note, paths []string, facet string, edges bool) {
+	members := make(map[string][]string)
+	for _, path := range paths {
+		if value := facetValue(files[path], facet); value != "" {
+			members[value] = append(members[value], path)
+		}
+	}
+	values := make([]string, 0, len(members))
+	for value := range members {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		id := facet + ":" + value
+		graph.Nodes = append(graph.Nodes, GraphNode{ID: id, Kind: facet, Label: value, Count: len(members[value])})
+		if edges {
+			for _, path := range members[value] {
+				graph.Edges = append(graph.Edges, GraphEdge{Source: NoteNodeID(path), Target: id, Kind: EdgeFacet, Weight: 1, Directed: true})
+			}
+		}
+	}
+}
+
+// noteLabel returns the title of a note, or its file name without the extension.
+func noteLabel(path string, note internal.Note) string {
+	if note.Title != "" {
+		return note.Title
+	}
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+func kindIndex(kind string) int {
+	for i, k := range EdgeKinds {
+		if k == kind {
+			return i
+		}
+	}
+	return len(EdgeKinds)
+}
+
+// GraphFilter narrows a graph to part of the vault.
+type GraphFilter struct {
+	// Tag keeps the notes using the tag or a tag nested below it.
+	Tag string
+	// Project keeps the notes of the project.
+	Project string
+	// Focus is the path of a note. When set, only the nodes at most Depth edges away
+	// from it are kept, following edges in both directions.
+	Focus string
+	Depth int
+}
+
+/*
+FilterGraph builds the graph of the notes matching a filter, see BuildGraph.
+
+Usage:
+
+	graph := index.FilterGraph(snapshot.Files, snapshot.Links(), opts, index.GraphFilter{Focus: path, Depth: 2})
+
+Parameters:
+
+	files (map[string]internal.Note): the notes by file path
+	links (*LinkGraph): the resolved links of the notes
+	opts (GraphOptions): the kinds of nodes and edges to include
+	filter (GraphFilter): the notes to keep
+
+Returns:
+
+	(*Graph): the graph
+*/
+func FilterGraph(files map[string]internal.Note, links *LinkGraph, opts GraphOptions, filter GraphFilter) *Graph {
+	selected := make(map[string]internal.Note, len(files))
+	for path, note := range files {
+		if filter.Project != "" && note.Project != filter.Project {
+			continue
+		}
+		if filter.Tag != "" && !usesTag(note, filter.Tag) {
+			continue
+		}
+		selected[path] = note
+	}
+	graph := BuildGraph(selected, links, opts)
+	if filter.Focus == "" {
+		return graph
+	}
+	return neighbourhood(graph, NoteNodeID(filter.Focus), filter.Depth)
+}
+
+// usesTag reports whether a note uses a tag or a tag nested below it.
+func usesTag(note internal.Note, tag string) bool {
+	for _, line := range note.Lines {
+		if line.Tag == tag || IsDescendant(line.Tag, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// neighbourhood returns the nodes at most depth edges away from a node, with the edges
+// between them. The graph is empty when it does not have the node.
+func neighbourhood(graph *Graph, id string, depth int) *Graph {
+	adjacent := make(map[string][]string)
+	for _, edge := range graph.Edges {
+		adjacent[edge.Source] = append(adjacent[edge.Source], edge.Target)
+		adjacent[edge.Target] = append(adjacent[edge.Target], edge.Source)
+	}
+
+	kept := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		if node.ID == id {
+			kept[id] = true
+		}
+	}
+	frontier := []string{}
+	if kept[id] {
+		frontier = append(frontier, id)
+	}
+	for d := 0; d < depth && len(frontier) > 0; d++ {
+		var next []string
+		for _, node := range frontier {
+			for _, other := range adjacent[node] {
+				if !kept[other] {
+					kept[other] = true
+					next = append(next, other)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	result := &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	for _, node := range graph.Nodes {
+		if kept[node.ID] {
+			result.Nodes = append(result.Nodes, node)
+		}
+	}
+	for _, edge := range graph.Edges {
+		if kept[edge.Source] && kept[edge.Target] {
+			result.Edges = append(result.Edges, edge)
+		}
+	}
+	return result
+}
+
+/*
+LimitGraph keeps the nodes with the most edges, so that a graph of a whole vault stays
+small enough to draw. Nodes with as many edges keep their order; edges are kept when
+both of their nodes are.
+
+Usage:
+
+	graph, truncated := index.LimitGraph(graph, 1000)
+
+Parameters:
+
+	graph (*Graph): the graph
+	max (int): the maximum number of nodes
+
+Returns:
+
+	(*Graph): the graph itself when it has at most max nodes, or else a smaller copy
+	(bool): true if nodes were left out
+*/
+func LimitGraph(graph *Graph, max int) (*Graph, bool) {
+	if len(graph.Nodes) <= max {
+		return graph, false
+	}
+	degree := make(map[string]int, len(graph.Nodes))
+	for _, edge := range graph.Edges {
+		degree[edge.Source]++
+		degree[edge.Target]++
+	}
+	order := make([]int, len(graph.Nodes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return degree[graph.Nodes[order[i]].ID] > degree[graph.Nodes[order[j]].ID]
+	})
+	kept := make([]bool, len(graph.Nodes))
+	for _, i := range order[:max] {
+		kept[i] = true
+	}
+
+	result := &Graph{Nodes: make([]GraphNode, 0, max), Edges: []GraphEdge{}}
+	keptIDs := make(map[string]bool, max)
+	for i, node := range graph.Nodes {
+		if kept[i] {
+			result.Nodes = append(result.Nodes, node)
+			keptIDs[node.ID] = true
+		}
+	}
+	for _, edge := range graph.Edges {
+		if keptIDs[edge.Source] && keptIDs[edge.Target] {
+			result.Edges = append(result.Edges, edge)
+		}
+	}
+	return result, true
+}
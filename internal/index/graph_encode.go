@@ -0,0 +1,243 @@
+package index
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// GraphFormats lists the formats WriteGraph understands.
+var GraphFormats = []string{"dot", "graphml", "gexf", "json"}
+
+/*
+WriteGraph writes a graph for Graphviz (dot), yEd and Gephi (graphml, gexf) or D3 and
+NetworkX (json, in node-link form). The kind, label, path, count and weight of nodes
+and edges are kept as attributes; undirected edges are marked as such.
+
+Usage:
+
+	err := index.WriteGraph(os.Stdout, graph, "gexf")
+
+Parameters:
+
+	w (io.Writer): the writer
+	graph (*Graph): the graph
+	format (string): one of GraphFormats
+
+Returns:
+
+	(error): if the format is unknown or writing failed
+*/
+func WriteGraph(w io.Writer, graph *Graph, format string) error {
+	switch format {
+	case "dot":
+		return writeDOT(w, graph)
+	case "graphml":
+		return writeXML(w, graphML(graph))
+	case "gexf":
+		return writeXML(w, gexf(graph))
+	case "json":
+		return json.NewEncoder(w).Encode(struct {
+			Directed   bool              `json:"directed"`
+			Multigraph bool              `json:"multigraph"`
+			Meta       map[string]string `json:"graph"`
+			Nodes      []GraphNode       `json:"nodes"`
+			Edges      []GraphEdge       `json:"links"`
+		}{true, true, map[string]string{"name": "zettelo"}, graph.Nodes, graph.Edges})
+	}
+	return fmt.Errorf("unknown graph format %q, expected one of %s", format, strings.Join(GraphFormats, ", "))
+}
+
+func writeDOT(w io.Writer, graph *Graph) error {
+	var sb strings.Builder
+	sb.WriteString("digraph zettelo {\n")
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(&sb, "  %s [label=%s, kind=%s", dotQuote(node.ID), dotQuote(node.Label), dotQuote(node.Kind))
+		if node.Kind != NodeNote {
+			sb.WriteString(", shape=box")
+		}
+		if node.Count > 0 {
+			fmt.Fprintf(&sb, ", count=%d", node.Count)
+		}
+		sb.WriteString("];\n")
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&sb, "  %s -> %s [kind=%s, weight=%d", dotQuote(edge.Source), dotQuote(edge.Target), dotQuote(edge.Kind), edge.Weight)
+		if !edge.Directed {
+			sb.WriteString(", dir=none")
+		}
+		sb.WriteString("];\n")
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// dotQuote returns s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type xmlGraphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLItem `xml:"node"`
+		Edges       []graphMLItem `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLItem struct {
+	ID       string        `xml:"id,attr"`
+	Source   string        `xml:"source,attr,omitempty"`
+	Target   string        `xml:"target,attr,omitempty"`
+	Directed string        `xml:"directed,attr,omitempty"`
+	Data     []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func graphML(graph *Graph) xmlGraphML {
+	doc := xmlGraphML{XMLNS: "http://graphml.graphdrawing.org/xmlns", Keys: []graphMLKey{
+		{ID: "label", For: "node", Name: "label", Type: "string"},
+		{ID: "kind", For: "node", Name: "kind", Type: "string"},
+		{ID: "path", For: "node", Name: "path", Type: "string"},
+		{ID: "count", For: "node", Name: "count", Type: "int"},
+		{ID: "edge_kind", For: "edge", Name: "kind", Type: "string"},
+		{ID: "weight", For: "edge", Name: "weight", Type: "int"},
+	}}
+	doc.Graph.ID = "zettelo"
+	doc.Graph.EdgeDefault = "directed"
+	for _, node := range graph.Nodes {
+		item := graphMLItem{ID: node.ID, Data: []graphMLData{{"label", node.Label}, {"kind", node.Kind}}}
+		if node.Path != "" {
+			item.Data = append(item.Data, graphMLData{"path", node.Path})
+		}
+		if node.Count > 0 {
+			item.Data = append(item.Data, graphMLData{"count", strconv.Itoa(node.Count)})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, item)
+	}
+	for i, edge := range graph.Edges {
+		item := graphMLItem{ID: "e" + strconv.Itoa(i), Source: edge.Source, Target: edge.Target, Data: []graphMLData{
+			{"edge_kind", edge.Kind},
+			{"weight", strconv.Itoa(edge.Weight)},
+		}}
+		if !edge.Directed {
+			item.Directed = "false"
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, item)
+	}
+	return doc
+}
+
+type xmlGEXF struct {
+	XMLName xml.Name `xml:"gexf"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Graph   struct {
+		DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+		Mode            string           `xml:"mode,attr"`
+		Attributes      []gexfAttributes `xml:"attributes"`
+		Nodes           []gexfNode       `xml:"nodes>node"`
+		Edges           []gexfEdge       `xml:"edges>edge"`
+	} `xml:"graph"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID     string         `xml:"id,attr"`
+	Label  string         `xml:"label,attr"`
+	Values []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     string         `xml:"id,attr"`
+	Source string         `xml:"source,attr"`
+	Target string         `xml:"target,attr"`
+	Type   string         `xml:"type,attr,omitempty"`
+	Weight int            `xml:"weight,attr"`
+	Values []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// gexfClass declares the attributes of nodes or edges; their IDs are their titles.
+func gexfClass(class string, titles ...string) gexfAttributes {
+	attrs := gexfAttributes{Class: class}
+	for _, title := range titles {
+		typ := "string"
+		if title == "count" {
+			typ = "integer"
+		}
+		attrs.Attributes = append(attrs.Attributes, gexfAttribute{ID: title, Title: title, Type: typ})
+	}
+	return attrs
+}
+
+func gexf(graph *Graph) xmlGEXF {
+	doc := xmlGEXF{XMLNS: "http://gexf.net/1.3", Version: "1.3"}
+	doc.Graph.DefaultEdgeType = "directed"
+	doc.Graph.Mode = "static"
+	doc.Graph.Attributes = []gexfAttributes{gexfClass("node", "kind", "path", "count"), gexfClass("edge", "kind")}
+	for _, node := range graph.Nodes {
+		item := gexfNode{ID: node.ID, Label: node.Label, Values: []gexfAttValue{{"kind", node.Kind}}}
+		if node.Path != "" {
+			item.Values = append(item.Values, gexfAttValue{"path", node.Path})
+		}
+		if node.Count > 0 {
+			item.Values = append(item.Values, gexfAttValue{"count", strconv.Itoa(node.Count)})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, item)
+	}
+	for i, edge := range graph.Edges {
+		item := gexfEdge{ID: strconv.Itoa(i), Source: edge.Source, Target: edge.Target, Weight: edge.Weight, Values: []gexfAttValue{{"kind", edge.Kind}}}
+		if !edge.Directed {
+			item.Type = "undirected"
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, item)
+	}
+	return doc
+}
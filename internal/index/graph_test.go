@@ -0,0 +1,210 @@
+package index_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/index"
+)
+
+func graphNotes() map[string]internal.Note {
+	tagged := func(path string, tags ...string) internal.TagList {
+		var lines internal.TagList
+		for _, tag := range tags {
+			lines = append(lines, internal.TaggedLine{Tag: tag, Values: []internal.ResultValue{{FilePath: path}}})
+		}
+		return lines
+	}
+	return map[string]internal.Note{
+		"/v/a.md": {Title: "A", Project: "calc", Lines: tagged("/v/a.md", "#idea", "#math", "#idea"), Links: []internal.Link{
+			{Kind: internal.LinkWiki, Target: "b"},
+			{Kind: internal.LinkWiki, Target: "b"},
+			{Kind: internal.LinkWiki, Target: "missing"},
+		}},
+		"/v/b.md": {Project: "calc", Type: "concept", Lines: tagged("/v/b.md", "#idea")},
+		"/v/c.md": {Lines: tagged("/v/c.md", "#math")},
+	}
+}
+
+func TestBuildGraph(t *testing.T) {
+	files := graphNotes()
+	graph := index.BuildGraph(files, index.BuildLinkGraph(files), index.GraphOptions{Nodes: []string{index.NodeTag, index.NodeType}})
+
+	expectedNodes := []index.GraphNode{
+		{ID: "note:/v/a.md", Kind: index.NodeNote, Label: "A", Path: "/v/a.md"},
+		{ID: "note:/v/b.md", Kind: index.NodeNote, Label: "b", Path: "/v/b.md"},
+		{ID: "note:/v/c.md", Kind: index.NodeNote, Label: "c", Path: "/v/c.md"},
+		{ID: "tag:#idea", Kind: index.NodeTag, Label: "#idea", Count: 2},
+		{ID: "tag:#math", Kind: index.NodeTag, Label: "#math", Count: 2},
+		{ID: "type:concept", Kind: index.NodeType, Label: "concept", Count: 1},
+	}
+	if !reflect.DeepEqual(graph.Nodes, expectedNodes) {
+		t.Errorf("Expected nodes %+v, got %+v", expectedNodes, graph.Nodes)
+	}
+
+	expectedEdges := []index.GraphEdge{
+		{Source: "note:/v/a.md", Target: "note:/v/b.md", Kind: index.EdgeLink, Weight: 2, Directed: true},
+		{Source: "note:/v/a.md", Target: "tag:#idea", Kind: index.EdgeTag, Weight: 2, Directed: true},
+		{Source: "note:/v/a.md", Target: "tag:#math", Kind: index.EdgeTag, Weight: 1, Directed: true},
+		{Source: "note:/v/b.md", Target: "tag:#idea", Kind: index.EdgeTag, Weight: 1, Directed: true},
+		{Source: "note:/v/c.md", Target: "tag:#math", Kind: index.EdgeTag, Weight: 1, Directed: true},
+		{Source: "tag:#idea", Target: "tag:#math", Kind: index.EdgeCooccurrence, Weight: 1},
+		{Source: "note:/v/a.md", Target: "note:/v/b.md", Kind: index.EdgeSharedProject, Weight: 1},
+		{Source: "note:/v/b.md", Target: "type:concept", Kind: index.EdgeFacet, Weight: 1, Directed: true},
+	}
+	if !reflect.DeepEqual(graph.Edges, expectedEdges) {
+		t.Errorf("Expected edges %+v, got %+v", expectedEdges, graph.Edges)
+	}
+}
+
+func TestBuildGraphEdgeKinds(t *testing.T) {
+	files := graphNotes()
+	opts, err := index.ParseGraphOptions("", "link, shared_project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := index.BuildGraph(files, index.BuildLinkGraph(files), opts)
+	if len(graph.Nodes) != 3 || len(graph.Edges) != 2 {
+		t.Errorf("Expected 3 notes joined by a link and a shared project, got %+v", graph)
+	}
+
+	if _, err := index.ParseGraphOptions("notes", ""); err == nil {
+		t.Error("Expected an error for an unknown node kind")
+	}
+}
+
+func TestWriteGraph(t *testing.T) {
+	files := graphNotes()
+	graph := index.BuildGraph(files, index.BuildLinkGraph(files), index.GraphOptions{Nodes: []string{index.NodeTag}})
+
+	for _, format := range index.GraphFormats {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := index.WriteGraph(&buf, graph, format); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			switch format {
+			case "dot":
+				if !strings.Contains(out, `"tag:#idea" -> "tag:#math" [kind="cooccurrence", weight=1, dir=none];`) {
+					t.Errorf("Expected an undirected co-occurrence edge, got\n%s", out)
+				}
+			case "json":
+				var doc struct {
+					Nodes []index.GraphNode `json:"nodes"`
+					Links []index.GraphEdge `json:"links"`
+				}
+				if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(doc.Nodes, graph.Nodes) || !reflect.DeepEqual(doc.Links, graph.Edges) {
+					t.Errorf("Expected the graph in node-link form, got %s", out)
+				}
+			default:
+				var doc struct {
+					Nodes []struct {
+						ID string `xml:"id,attr"`
+					} `xml:"graph>node"`
+					GEXFNodes []struct {
+						ID string `xml:"id,attr"`
+					} `xml:"graph>nodes>node"`
+				}
+				if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+					t.Fatal(err)
+				}
+				if n := len(doc.Nodes) + len(doc.GEXFNodes); n != len(graph.Nodes) {
+					t.Errorf("Expected %d nodes, got %d in\n%s", len(graph.Nodes), n, out)
+				}
+			}
+		})
+	}
+
+	if err := index.WriteGraph(&bytes.Buffer{}, graph, "svg"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestFilterGraph(t *testing.T) {
+	files := graphNotes()
+	files["/v/d.md"] = internal.Note{Lines: internal.TagList{{Tag: "#math/calculus"}}, Links: []internal.Link{{Kind: internal.LinkWiki, Target: "c"}}}
+	links := index.BuildLinkGraph(files)
+	opts := index.GraphOptions{Nodes: []string{index.NodeTag}, Edges: []string{index.EdgeLink, index.EdgeTag}}
+
+	nodeIDs := func(graph *index.Graph) []string {
+		var ids []string
+		for _, node := range graph.Nodes {
+			ids = append(ids, node.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name     string
+		filter   index.GraphFilter
+		expected []string
+	}{
+		{"tag with nested tags", index.GraphFilter{Tag: "#math"}, []string{"note:/v/a.md", "note:/v/c.md", "note:/v/d.md", "tag:#idea", "tag:#math", "tag:#math/calculus"}},
+		{"project", index.GraphFilter{Project: "calc"}, []string{"note:/v/a.md", "note:/v/b.md", "tag:#idea", "tag:#math"}},
+		{"focus", index.GraphFilter{Focus: "/v/d.md", Depth: 1}, []string{"note:/v/c.md", "note:/v/d.md", "tag:#math/calculus"}},
+		{"focus two edges away", index.GraphFilter{Focus: "/v/b.md", Depth: 2}, []string{"note:/v/a.md", "note:/v/b.md", "tag:#idea", "tag:#math"}},
+		{"focus filtered out", index.GraphFilter{Project: "calc", Focus: "/v/d.md", Depth: 1}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			graph := index.FilterGraph(files, links, opts, test.filter)
+			if ids := nodeIDs(graph); !reflect.DeepEqual(ids, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, ids)
+			}
+			for _, edge := range graph.Edges {
+				if !contains(nodeIDs(graph), edge.Source) || !contains(nodeIDs(graph), edge.Target) {
+					t.Errorf("Expected only edges between kept nodes, got %+v", edge)
+				}
+			}
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLimitGraph(t *testing.T) {
+	files := graphNotes()
+	graph := index.BuildGraph(files, index.BuildLinkGraph(files), index.GraphOptions{Nodes: []string{index.NodeTag}, Edges: []string{index.EdgeLink, index.EdgeTag}})
+
+	if limited, truncated := index.LimitGraph(graph, len(graph.Nodes)); truncated || limited != graph {
+		t.Errorf("Expected a graph within the limit to be kept, got %+v", limited)
+	}
+
+	// a.md has 3 edges, the tags 2 each, b.md 2 and c.md 1.
+	limited, truncated := index.LimitGraph(graph, 3)
+	if !truncated {
+		t.Error("Expected the graph to be truncated")
+	}
+	var ids []string
+	for _, node := range limited.Nodes {
+		ids = append(ids, node.ID)
+	}
+	expected := []string{"note:/v/a.md", "note:/v/b.md", "tag:#idea"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Expected the most connected nodes %v, got %v", expected, ids)
+	}
+	for _, edge := range limited.Edges {
+		if !contains(ids, edge.Source) || !contains(ids, edge.Target) {
+			t.Errorf("Expected only edges between kept nodes, got %+v", edge)
+		}
+	}
+	if len(limited.Edges) != 3 {
+		t.Errorf("Expected 3 edges between the kept nodes, got %+v", limited.Edges)
+	}
+}
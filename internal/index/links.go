@@ -0,0 +1,240 @@
+package index
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ozcankasal/zettelo/internal"
+)
+
+// Link statuses.
+const (
+	LinkResolved   = "resolved"
+	LinkUnresolved = "unresolved"
+	LinkAmbiguous  = "ambiguous"
+)
+
+// ResolvedLink is a link together with the note it points to.
+type ResolvedLink struct {
+	internal.Link
+	// Source is the path of the note containing the link.
+	Source string `json:"source"`
+	Status string `json:"status"`
+	// Path is the linked note of a resolved link; Candidates lists the notes an ambiguous
+	// link may mean.
+	Path       string   `json:"path,omitempty"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// Resolver finds the notes that link targets name.
+type Resolver struct {
+	files   map[string]internal.Note
+	byID    map[string][]string
+	byName  map[string][]string
+	byTitle map[string][]string
+	byAlias map[string][]string
+}
+
+/*
+NewResolver indexes the IDs, file names, titles and aliases of the notes. File names,
+titles and aliases are matched regardless of case.
+
+Usage:
+
+	resolver := index.NewResolver(snapshot.Files)
+	link := resolver.Resolve(path, note.Links[0])
+
+Parameters:
+
+	files (map[string]internal.Note): the notes by file path
+
+Returns:
+
+	(*Resolver): the resolver
+*/
+func NewResolver(files map[string]internal.Note) *Resolver {
+	r := &Resolver{
+		files:   files,
+		byID:    make(map[string][]string),
+		byName:  make(map[string][]string),
+		byTitle: make(map[string][]string),
+		byAlias: make(map[string][]string),
+	}
+	for p, note := range files {
+		if note.ID != "" {
+			r.byID[note.ID] = append(r.byID[note.ID], p)
+		}
+		r.byName[nameKey(filepath.Base(p))] = append(r.byName[nameKey(filepath.Base(p))], p)
+		if note.Title != "" {
+			r.byTitle[strings.ToLower(note.Title)] = append(r.byTitle[strings.ToLower(note.Title)], p)
+		}
+		for _, alias := range note.Aliases {
+			r.byAlias[strings.ToLower(alias)] = append(r.byAlias[strings.ToLower(alias)], p)
+		}
+	}
+	for _, m := range []map[string][]string{r.byID, r.byName, r.byTitle, r.byAlias} {
+		for _, paths := range m {
+			sort.Strings(paths)
+		}
+	}
+	return r
+}
+
+// nameKey returns the lower case file name without a markdown extension.
+func nameKey(name string) string {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".md", ".markdown"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+/*
+Resolve finds the note a link points to. A wiki link is looked up by ID, then by file
+name, then by title and then by alias; the first of these that matches decides. A target
+containing '/' must match the end of the path of the note. A markdown link is resolved
+relative to the note containing it, with or without the .md extension.
+
+Usage:
+
+	resolved := resolver.Resolve("/notes/a.md", link)
+
+Parameters:
+
+	source (string): the path of the note containing the link
+	link (internal.Link): the link
+
+Returns:
+
+	(ResolvedLink): the link with its status and the linked note or candidates
+*/
+func (r *Resolver) Resolve(source string, link internal.Link) ResolvedLink {
+	resolved := ResolvedLink{Link: link, Source: source, Status: LinkUnresolved}
+	var paths []string
+	switch {
+	case link.Target == "":
+		// A link to a heading of the note itself.
+		paths = []string{source}
+	case link.Kind == internal.LinkMarkdown:
+		target := filepath.Join(filepath.Dir(source), filepath.FromSlash(link.Target))
+		for _, candidate := range []string{target, target + ".md"} {
+			if _, ok := r.files[candidate]; ok {
+				paths = []string{candidate}
+				break
+			}
+		}
+	default:
+		paths = r.lookup(link.Target)
+	}
+
+	switch len(paths) {
+	case 0:
+	case 1:
+		resolved.Status, resolved.Path = LinkResolved, paths[0]
+	default:
+		resolved.Status, resolved.Candidates = LinkAmbiguous, paths
+	}
+	return resolved
+}
+
+// lookup returns the notes a wiki link target names.
+func (r *Resolver) lookup(target string) []string {
+	if paths := r.byID[target]; len(paths) > 0 {
+		return paths
+	}
+	key := nameKey(target)
+	if dir, name := path.Split(key); dir != "" {
+		var paths []string
+		for _, p := range r.byName[name] {
+			if strings.HasSuffix(nameKey(filepath.ToSlash(p)), "/"+key) {
+				paths = append(paths, p)
+			}
+		}
+		return paths
+	}
+	if paths := r.byName[key]; len(paths) > 0 {
+		return paths
+	}
+	if paths := r.byTitle[strings.ToLower(target)]; len(paths) > 0 {
+		return paths
+	}
+	return r.byAlias[strings.ToLower(target)]
+}
+
+// LinkGraph holds the resolved links between the notes of a snapshot.
+type LinkGraph struct {
+	// Links maps the path of every note with links to its links, in document order.
+	Links map[string][]ResolvedLink
+	// Backlinks maps the path of every linked note to the links from other notes
+	// pointing to it, ordered by source and position.
+	Backlinks map[string][]ResolvedLink
+}
+
+/*
+BuildLinkGraph resolves the links of every note and collects the backlinks.
+
+Usage:
+
+	graph := index.BuildLinkGraph(snapshot.Files)
+	for _, backlink := range graph.Backlinks[path] {
+		fmt.Println(backlink.Source)
+	}
+
+Parameters:
+
+	files (map[string]internal.Note): the notes by file path
+
+Returns:
+
+	(*LinkGraph): the link graph
+*/
+func BuildLinkGraph(files map[string]internal.Note) *LinkGraph {
+	resolver := NewResolver(files)
+	graph := &LinkGraph{Links: make(map[string][]ResolvedLink), Backlinks: make(map[string][]ResolvedLink)}
+	for source, note := range files {
+		if len(note.Links) == 0 {
+			continue
+		}
+		links := make([]ResolvedLink, len(note.Links))
+		for i, link := range note.Links {
+			links[i] = resolver.Resolve(source, link)
+			if links[i].Status == LinkResolved && links[i].Path != source {
+				graph.Backlinks[links[i].Path] = append(graph.Backlinks[links[i].Path], links[i])
+			}
+		}
+		graph.Links[source] = links
+	}
+	for _, backlinks := range graph.Backlinks {
+		sortLinks(backlinks)
+	}
+	return graph
+}
+
+// Problems returns the unresolved and ambiguous links, ordered by source and position.
+func (g *LinkGraph) Problems() []ResolvedLink {
+	problems := []ResolvedLink{}
+	for _, links := range g.Links {
+		for _, link := range links {
+			if link.Status != LinkResolved {
+				problems = append(problems, link)
+			}
+		}
+	}
+	sortLinks(problems)
+	return problems
+}
+
+func sortLinks(links []ResolvedLink) {
+	sort.Slice(links, func(i, j int) bool {
+		a, b := links[i], links[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.LineNumber != b.LineNumber {
+			return a.LineNumber < b.LineNumber
+		}
+		return a.Column < b.Column
+	})
+}
@@ -0,0 +1,90 @@
+package index_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/index"
+)
+
+func TestResolve(t *testing.T) {
+	files := map[string]internal.Note{
+		"/v/limits.md":        {ID: "42", Title: "Limits of Functions", Aliases: []string{"lim"}},
+		"/v/sub/Series.md":    {Title: "Series"},
+		"/v/a/index.md":       {Title: "Index A"},
+		"/v/b/index.md":       {Title: "Index B", Aliases: []string{"lim"}},
+		"/v/calculus/42.md":   {},
+		"/v/sub/integrals.md": {Aliases: []string{"Integration"}},
+	}
+	resolver := index.NewResolver(files)
+	wiki := func(target string) internal.Link { return internal.Link{Kind: internal.LinkWiki, Target: target} }
+	markdown := func(target string) internal.Link { return internal.Link{Kind: internal.LinkMarkdown, Target: target} }
+
+	tests := []struct {
+		name   string
+		link   internal.Link
+		status string
+		path   string
+	}{
+		{"ID before file name", wiki("42"), index.LinkResolved, "/v/limits.md"},
+		{"file name", wiki("limits"), index.LinkResolved, "/v/limits.md"},
+		{"file name with extension and other case", wiki("series.MD"), index.LinkResolved, "/v/sub/Series.md"},
+		{"path suffix", wiki("b/index"), index.LinkResolved, "/v/b/index.md"},
+		{"title", wiki("limits of functions"), index.LinkResolved, "/v/limits.md"},
+		{"alias", wiki("integration"), index.LinkResolved, "/v/sub/integrals.md"},
+		{"same file name", wiki("index"), index.LinkAmbiguous, ""},
+		{"same alias", wiki("lim"), index.LinkAmbiguous, ""},
+		{"missing", wiki("nothing"), index.LinkUnresolved, ""},
+		{"heading of the note", wiki(""), index.LinkResolved, "/v/sub/Series.md"},
+		{"relative markdown", markdown("../limits.md"), index.LinkResolved, "/v/limits.md"},
+		{"markdown without extension", markdown("integrals"), index.LinkResolved, "/v/sub/integrals.md"},
+		{"missing markdown", markdown("limits.md"), index.LinkUnresolved, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved := resolver.Resolve("/v/sub/Series.md", test.link)
+			if resolved.Status != test.status || resolved.Path != test.path {
+				t.Errorf("Expected %s %q, got %s %q", test.status, test.path, resolved.Status, resolved.Path)
+			}
+		})
+	}
+
+	if resolved := resolver.Resolve("/v/limits.md", wiki("index")); !reflect.DeepEqual(resolved.Candidates, []string{"/v/a/index.md", "/v/b/index.md"}) {
+		t.Errorf("Expected both index notes as candidates, got %v", resolved.Candidates)
+	}
+}
+
+func TestBuildLinkGraph(t *testing.T) {
+	files := map[string]internal.Note{
+		"/v/a.md": {Links: []internal.Link{
+			{Kind: internal.LinkWiki, Target: "c", LineNumber: 3, Column: 1},
+			{Kind: internal.LinkWiki, Target: "missing", LineNumber: 4, Column: 7},
+			{Kind: internal.LinkWiki, Heading: "Intro", LineNumber: 5, Column: 1},
+		}},
+		"/v/b.md": {Links: []internal.Link{
+			{Kind: internal.LinkMarkdown, Target: "c.md", LineNumber: 1, Column: 2},
+		}},
+		"/v/c.md": {},
+	}
+	graph := index.BuildLinkGraph(files)
+
+	if links := graph.Links["/v/a.md"]; len(links) != 3 || links[2].Path != "/v/a.md" {
+		t.Errorf("Expected the links of a.md in document order, got %+v", links)
+	}
+	var sources []string
+	for _, link := range graph.Backlinks["/v/c.md"] {
+		sources = append(sources, link.Source)
+	}
+	if !reflect.DeepEqual(sources, []string{"/v/a.md", "/v/b.md"}) {
+		t.Errorf("Expected backlinks from a.md and b.md, got %v", sources)
+	}
+	if backlinks := graph.Backlinks["/v/a.md"]; len(backlinks) != 0 {
+		t.Errorf("Expected links within a note not to be backlinks, got %+v", backlinks)
+	}
+
+	problems := graph.Problems()
+	if len(problems) != 1 || problems[0].Target != "missing" || problems[0].Status != index.LinkUnresolved || problems[0].LineNumber != 4 {
+		t.Errorf("Expected the link to missing to be unresolved, got %+v", problems)
+	}
+}
@@ -0,0 +1,423 @@
+/*
+Package index holds the tag index built from the notes of a vault.
+
+The index is published as immutable snapshots. Every change produces a new snapshot
+with a higher version number, which is swapped in atomically so that readers always
+see one consistent generation of the index.
+*/
+package index
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ozcankasal/zettelo/internal"
+)
+
+// Snapshot is one generation of the index. It must not be modified once published.
+type Snapshot struct {
+	// Version increases by one with every published snapshot.
+	Version uint64 `json:"version"`
+	// Tags is the aggregate tag list, sorted by tag and file path.
+	Tags internal.TagList `json:"tags"`
+	// Files holds the note of every indexed file.
+	Files map[string]internal.Note `json:"-"`
+	// Errors lists the files that could not be scanned, sorted by path.
+	Errors []internal.ScanError `json:"errors"`
+	// Tree arranges the tags by nesting, see BuildTree.
+	Tree []*TagNode `json:"tree"`
+	// Facets count the notes by type and project, see BuildFacets.
+	Facets []Facet `json:"facets"`
+
+	// links is resolved on first use; copies of the snapshot with the same files share it.
+	links   *lazyLinkGraph
+	encoded []byte
+}
+
+// lazyLinkGraph builds the link graph of a snapshot once it is asked for.
+type lazyLinkGraph struct {
+	once  sync.Once
+	graph *LinkGraph
+}
+
+// JSON returns the JSON encoding of the snapshot, computed once when it was published.
+func (s *Snapshot) JSON() []byte {
+	return s.encoded
+}
+
+// Links returns the resolved links between the notes, see BuildLinkGraph. They are
+// resolved on the first call rather than on every change, so saving a note does not
+// resolve the links of the whole vault again. It is safe to call from any goroutine.
+func (s *Snapshot) Links() *LinkGraph {
+	s.links.once.Do(func() { s.links.graph = BuildLinkGraph(s.Files) })
+	return s.links.graph
+}
+
+// Notes returns the notes of the snapshot sorted by path.
+func (s *Snapshot) Notes() []internal.Note {
+	notes := make([]internal.Note, 0, len(s.Files))
+	for _, note := range s.Files {
+		notes = append(notes, note)
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Path < notes[j].Path })
+	return notes
+}
+
+// Store holds the current snapshot of the index.
+type Store struct {
+	// mu serialises writers; readers only load current.
+	mu      sync.Mutex
+	current atomic.Pointer[Snapshot]
+}
+
+/*
+NewStore creates a store holding an empty snapshot with version 0.
+
+Usage:
+
+	store := index.NewStore()
+	snapshot := store.Snapshot()
+
+Returns:
+
+	(*Store): the new store
+*/
+func NewStore() *Store {
+	s := &Store{}
+	empty := &Snapshot{Tags: internal.TagList{}, Files: map[string]internal.Note{}, Errors: []internal.ScanError{}, Tree: []*TagNode{}, Facets: BuildFacets(nil), links: &lazyLinkGraph{}}
+	empty.encoded, _ = json.Marshal(empty)
+	s.current.Store(empty)
+	return s
+}
+
+// Snapshot returns the current snapshot. It is safe to call from any goroutine.
+func (s *Store) Snapshot() *Snapshot {
+	return s.current.Load()
+}
+
+/*
+Replace publishes a new snapshot built from the notes of every file.
+
+Usage:
+
+	snapshot := store.Replace(notesByFile, scanErrors)
+
+Parameters:
+
+	files (map[string]internal.Note): the notes by file path; the store takes ownership of the map
+	scanErrors ([]internal.ScanError): the files that could not be scanned
+
+Returns:
+
+	(*Snapshot): the published snapshot
+*/
+func (s *Store) Replace(files map[string]internal.Note, scanErrors []internal.ScanError) *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := append([]internal.ScanError{}, scanErrors...)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return s.publish(&Snapshot{Tags: aggregate(files), Files: files, Errors: errs})
+}
+
+/*
+UpdateFile publishes a new snapshot in which the note of one file is replaced. Only the
+tags used by the file before or after the update are recomputed.
+
+Usage:
+
+	snapshot := store.UpdateFile("notes/a.md", utils.ParseNote("notes/a.md", data, config))
+
+Parameters:
+
+	path (string): the path of the file
+	note (internal.Note): the note parsed from the file
+
+Returns:
+
+	(*Snapshot): the published snapshot
+*/
+func (s *Store) UpdateFile(path string, note internal.Note) *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.current.Load()
+	next := apply(previous, path, note, true)
+	next = clearErrors(previous, next, func(p string) bool { return p == path })
+	return s.publish(next)
+}
+
+/*
+FailFile publishes a new snapshot in which the file is no longer indexed and is reported
+as an error instead.
+
+Usage:
+
+	snapshot := store.FailFile(path, scanner.NewError(path, err))
+
+Parameters:
+
+	path (string): the path of the file
+	scanErr (internal.ScanError): the reason the file could not be scanned
+
+Returns:
+
+	(*Snapshot): the published snapshot
+*/
+func (s *Store) FailFile(path string, scanErr internal.ScanError) *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.current.Load()
+	next := apply(previous, path, internal.Note{}, false)
+	next = clearErrors(previous, next, func(p string) bool { return p == path })
+	if next == previous {
+		copied := *previous
+		next = &copied
+	}
+
+	errs := make([]internal.ScanError, 0, len(next.Errors)+1)
+	i := sort.Search(len(next.Errors), func(i int) bool { return next.Errors[i].Path > path })
+	errs = append(errs, next.Errors[:i]...)
+	errs = append(errs, scanErr)
+	errs = append(errs, next.Errors[i:]...)
+	next.Errors = errs
+	return s.publish(next)
+}
+
+/*
+RemoveFile publishes a new snapshot without the entries of one file.
+
+Usage:
+
+	snapshot := store.RemoveFile("notes/a.md")
+
+Parameters:
+
+	path (string): the path of the file
+
+Returns:
+
+	(*Snapshot): the published snapshot
+*/
+func (s *Store) RemoveFile(path string) *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.publish(apply(s.current.Load(), path, internal.Note{}, false))
+}
+
+/*
+RemoveTree publishes a new snapshot without the entries of path and of every file below it.
+It is used when a file or a whole directory is deleted or renamed.
+
+Usage:
+
+	snapshot := store.RemoveTree("notes/archive")
+
+Parameters:
+
+	path (string): the path of the removed file or directory
+
+Returns:
+
+	(*Snapshot): the published snapshot
+*/
+func (s *Store) RemoveTree(path string) *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.current.Load()
+	prefix := path + string(filepath.Separator)
+	below := func(p string) bool { return p == path || strings.HasPrefix(p, prefix) }
+	return s.publish(clearErrors(previous, removeFiles(previous, below), below))
+}
+
+// publish encodes and stores the snapshot as the next version. Publishing the current
+// snapshot again is a no-op. The caller must hold s.mu.
+func (s *Store) publish(snapshot *Snapshot) *Snapshot {
+	previous := s.current.Load()
+	if snapshot == previous {
+		return previous
+	}
+	snapshot.Version = previous.Version + 1
+	snapshot.Tree = BuildTree(snapshot.Tags)
+	snapshot.Facets = BuildFacets(snapshot.Files)
+	if snapshot.links == nil {
+		snapshot.links = &lazyLinkGraph{}
+	}
+	// Marshalling a TagList cannot fail.
+	snapshot.encoded, _ = json.Marshal(snapshot)
+	s.current.Store(snapshot)
+	return snapshot
+}
+
+// apply returns an unpublished snapshot in which the entries of path are replaced, or
+// removed when present is false. It returns previous when nothing changes.
+func apply(previous *Snapshot, path string, note internal.Note, present bool) *Snapshot {
+	old, existed := previous.Files[path]
+	if !existed && !present {
+		return previous
+	}
+
+	files := make(map[string]internal.Note, len(previous.Files)+1)
+	for file, fileNote := range previous.Files {
+		files[file] = fileNote
+	}
+	if present {
+		files[path] = note
+	} else {
+		delete(files, path)
+	}
+
+	// Only the tags of the old and new entries change.
+	newValues := groupTaggedLines(map[string]internal.Note{path: note})
+	affected := make(map[string]bool)
+	for _, line := range old.Lines {
+		affected[line.Tag] = true
+	}
+	for tag := range newValues {
+		affected[tag] = true
+	}
+
+	tags := make(internal.TagList, 0, len(previous.Tags)+len(newValues))
+	for _, tagged := range previous.Tags {
+		if !affected[tagged.Tag] {
+			tags = append(tags, tagged)
+			continue
+		}
+		values := mergeValues(tagged.Values, path, newValues[tagged.Tag][path])
+		delete(newValues, tagged.Tag)
+		if len(values) > 0 {
+			tags = append(tags, internal.TaggedLine{Tag: tagged.Tag, Values: values})
+		}
+	}
+	if len(newValues) > 0 {
+		// Tags that were not in the index before.
+		for tag, fileLines := range newValues {
+			tags = append(tags, internal.TaggedLine{Tag: tag, Values: fileLines[path]})
+		}
+		sort.Sort(tags)
+	}
+
+	return &Snapshot{Tags: tags, Files: files, Errors: previous.Errors}
+}
+
+// removeFiles returns an unpublished snapshot without the files matching match, built in
+// one pass over the files and tags. It returns previous when no file matches.
+func removeFiles(previous *Snapshot, match func(path string) bool) *Snapshot {
+	files := make(map[string]internal.Note, len(previous.Files))
+	for file, note := range previous.Files {
+		if !match(file) {
+			files[file] = note
+		}
+	}
+	if len(files) == len(previous.Files) {
+		return previous
+	}
+
+	tags := make(internal.TagList, 0, len(previous.Tags))
+	for _, tagged := range previous.Tags {
+		values := make([]internal.ResultValue, 0, len(tagged.Values))
+		for _, value := range tagged.Values {
+			if !match(value.FilePath) {
+				values = append(values, value)
+			}
+		}
+		if len(values) == len(tagged.Values) {
+			tags = append(tags, tagged)
+		} else if len(values) > 0 {
+			tags = append(tags, internal.TaggedLine{Tag: tagged.Tag, Values: values})
+		}
+	}
+	return &Snapshot{Tags: tags, Files: files, Errors: previous.Errors}
+}
+
+// clearErrors returns next without the errors of the paths matching match. When next is
+// the current snapshot, a copy is returned instead of modifying it.
+func clearErrors(current, next *Snapshot, match func(path string) bool) *Snapshot {
+	errs := make([]internal.ScanError, 0, len(next.Errors))
+	for _, scanErr := range next.Errors {
+		if !match(scanErr.Path) {
+			errs = append(errs, scanErr)
+		}
+	}
+	if len(errs) == len(next.Errors) {
+		return next
+	}
+	if next == current {
+		copied := *current
+		next = &copied
+	}
+	next.Errors = errs
+	return next
+}
+
+// mergeValues returns a copy of values, which are ordered by file path, with the values of
+// path replaced by replacement.
+func mergeValues(values []internal.ResultValue, path string, replacement []internal.ResultValue) []internal.ResultValue {
+	merged := make([]internal.ResultValue, 0, len(values)+len(replacement))
+	inserted := false
+	for _, value := range values {
+		if value.FilePath == path {
+			continue
+		}
+		if !inserted && value.FilePath > path {
+			merged = append(merged, replacement...)
+			inserted = true
+		}
+		merged = append(merged, value)
+	}
+	if !inserted {
+		merged = append(merged, replacement...)
+	}
+	return merged
+}
+
+// groupTaggedLines groups the tagged lines by canonical type and file path
+func groupTaggedLines(files map[string]internal.Note) map[string]map[string][]internal.ResultValue {
+	groupedLines := make(map[string]map[string][]internal.ResultValue)
+
+	for file, note := range files {
+		for _, line := range note.Lines {
+			if _, ok := groupedLines[line.Tag]; !ok {
+				groupedLines[line.Tag] = make(map[string][]internal.ResultValue)
+			}
+			if len(line.Values) > 0 {
+				groupedLines[line.Tag][file] = append(groupedLines[line.Tag][file], line.Values...)
+			} else {
+				// Keep track of files using the tag without a value.
+				groupedLines[line.Tag][file] = append(groupedLines[line.Tag][file], internal.ResultValue{FilePath: file, Origin: internal.OriginHashtag})
+			}
+		}
+	}
+
+	return groupedLines
+}
+
+// aggregate converts the grouped lines to a TagList sorted by tag, with the values
+// of each tag ordered by file path.
+func aggregate(files map[string]internal.Note) internal.TagList {
+	groupedLines := groupTaggedLines(files)
+
+	tags := make(internal.TagList, 0, len(groupedLines))
+	for tag, fileLines := range groupedLines {
+		paths := make([]string, 0, len(fileLines))
+		for path := range fileLines {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		var values []internal.ResultValue
+		for _, path := range paths {
+			values = append(values, fileLines[path]...)
+		}
+		tags = append(tags, internal.TaggedLine{Tag: tag, Values: values})
+	}
+	sort.Sort(tags)
+	return tags
+}
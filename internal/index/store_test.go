@@ -0,0 +1,220 @@
+package index_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/index"
+)
+
+func TestReplaceAggregatesFiles(t *testing.T) {
+	store := index.NewStore()
+	if snapshot := store.Snapshot(); snapshot.Version != 0 || len(snapshot.Tags) != 0 {
+		t.Fatalf("Expected an empty snapshot with version 0, got %+v", snapshot)
+	}
+
+	snapshot := store.Replace(notes(map[string]internal.TagList{
+		"b.md": {
+			{Tag: "#todo", Values: []internal.ResultValue{{FilePath: "b.md", Line: "write tests"}}},
+		},
+		"a.md": {
+			{Tag: "#todo", Values: []internal.ResultValue{{FilePath: "a.md", Line: "fix bug"}}},
+			{Tag: "#idea", Values: []internal.ResultValue{}},
+		},
+	}), nil)
+
+	expected := internal.TagList{
+		{Tag: "#idea", Values: []internal.ResultValue{{FilePath: "a.md", Origin: internal.OriginHashtag}}},
+		{Tag: "#todo", Values: []internal.ResultValue{
+			{FilePath: "a.md", Line: "fix bug"},
+			{FilePath: "b.md", Line: "write tests"},
+		}},
+	}
+	if snapshot.Version != 1 {
+		t.Errorf("Expected version 1, got %d", snapshot.Version)
+	}
+	if !reflect.DeepEqual(snapshot.Tags, expected) {
+		t.Errorf("Expected tags %v, got %v", expected, snapshot.Tags)
+	}
+	if store.Snapshot() != snapshot {
+		t.Errorf("Expected the store to return the published snapshot")
+	}
+
+	var decoded struct {
+		Version uint64           `json:"version"`
+		Tags    internal.TagList `json:"tags"`
+	}
+	if err := json.Unmarshal(snapshot.JSON(), &decoded); err != nil {
+		t.Fatalf("Failed to decode snapshot JSON: %v", err)
+	}
+	if decoded.Version != 1 || !reflect.DeepEqual(decoded.Tags, expected) {
+		t.Errorf("Unexpected snapshot JSON: %s", snapshot.JSON())
+	}
+}
+
+func TestVersionsIncreaseUnderConcurrentWriters(t *testing.T) {
+	store := index.NewStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Replace(map[string]internal.Note{}, nil)
+			_ = store.Snapshot().Tags
+		}()
+	}
+	wg.Wait()
+
+	if version := store.Snapshot().Version; version != 50 {
+		t.Errorf("Expected version 50, got %d", version)
+	}
+}
+
+func TestUpdateFileMatchesFullRebuild(t *testing.T) {
+	files := map[string]internal.TagList{
+		"a.md": {
+			{Tag: "#todo", Values: []internal.ResultValue{{FilePath: "a.md", Line: "fix bug"}}},
+		},
+		"b.md": {
+			{Tag: "#todo", Values: []internal.ResultValue{{FilePath: "b.md", Line: "write tests"}}},
+			{Tag: "#idea", Values: []internal.ResultValue{{FilePath: "b.md", Line: "graph view"}}},
+		},
+		"c.md": {
+			{Tag: "#todo", Values: []internal.ResultValue{{FilePath: "c.md", Line: "release"}}},
+		},
+	}
+	updated := internal.TagList{
+		{Tag: "#todo", Values: []internal.ResultValue{{FilePath: "b.md", Line: "write more tests"}}},
+		{Tag: "#question", Values: []internal.ResultValue{}},
+	}
+
+	store := index.NewStore()
+	store.Replace(notes(files), nil)
+	snapshot := store.UpdateFile("b.md", internal.Note{Path: "b.md", Lines: updated})
+
+	files["b.md"] = updated
+	expected := index.NewStore().Replace(notes(files), nil)
+
+	if snapshot.Version != 2 {
+		t.Errorf("Expected version 2, got %d", snapshot.Version)
+	}
+	if !reflect.DeepEqual(snapshot.Tags, expected.Tags) {
+		t.Errorf("Expected tags %v, got %v", expected.Tags, snapshot.Tags)
+	}
+	if !reflect.DeepEqual(snapshot.Files, expected.Files) {
+		t.Errorf("Expected files %v, got %v", expected.Files, snapshot.Files)
+	}
+
+	snapshot = store.RemoveFile("b.md")
+	delete(files, "b.md")
+	expected = index.NewStore().Replace(notes(files), nil)
+	if !reflect.DeepEqual(snapshot.Tags, expected.Tags) {
+		t.Errorf("Expected tags %v after removal, got %v", expected.Tags, snapshot.Tags)
+	}
+
+	if again := store.RemoveFile("b.md"); again != snapshot {
+		t.Errorf("Expected removing an unknown file to keep the snapshot")
+	}
+}
+
+// notes returns a note holding the tagged lines of every file.
+func notes(files map[string]internal.TagList) map[string]internal.Note {
+	c := make(map[string]internal.Note, len(files))
+	for path, lines := range files {
+		c[path] = internal.Note{Path: path, Lines: lines}
+	}
+	return c
+}
+
+func TestRemoveTree(t *testing.T) {
+	store := index.NewStore()
+	store.Replace(notes(map[string]internal.TagList{
+		filepath.Join("notes", "a.md"):            {{Tag: "#todo", Values: []internal.ResultValue{}}},
+		filepath.Join("notes", "archive", "b.md"): {{Tag: "#todo", Values: []internal.ResultValue{}}, {Tag: "#old", Values: []internal.ResultValue{}}},
+		filepath.Join("notes", "archive2.md"):     {{Tag: "#idea", Values: []internal.ResultValue{}}},
+	}), nil)
+
+	snapshot := store.RemoveTree(filepath.Join("notes", "archive"))
+	if snapshot.Version != 2 {
+		t.Errorf("Expected a single new version, got %d", snapshot.Version)
+	}
+	if len(snapshot.Files) != 2 {
+		t.Errorf("Expected 2 files to remain, got %v", snapshot.Files)
+	}
+	if _, ok := snapshot.Files[filepath.Join("notes", "archive2.md")]; !ok {
+		t.Errorf("Expected a sibling with a common prefix to remain")
+	}
+	expected := internal.TagList{
+		{Tag: "#idea", Values: []internal.ResultValue{{FilePath: filepath.Join("notes", "archive2.md"), Origin: internal.OriginHashtag}}},
+		{Tag: "#todo", Values: []internal.ResultValue{{FilePath: filepath.Join("notes", "a.md"), Origin: internal.OriginHashtag}}},
+	}
+	if !reflect.DeepEqual(snapshot.Tags, expected) {
+		t.Errorf("Expected tags %v, got %v", expected, snapshot.Tags)
+	}
+
+	if again := store.RemoveTree(filepath.Join("notes", "archive")); again != snapshot {
+		t.Errorf("Expected removing a missing tree to keep the snapshot, got version %d", again.Version)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	store := index.NewStore()
+	store.Replace(notes(map[string]internal.TagList{
+		"a.md": {{Tag: "#todo", Values: []internal.ResultValue{{FilePath: "a.md", Line: "fix bug"}}}},
+	}), []internal.ScanError{{Path: "c.md", Kind: "permission"}})
+
+	snapshot := store.FailFile("a.md", internal.ScanError{Path: "a.md", Kind: "not_found"})
+	if len(snapshot.Files) != 0 || len(snapshot.Tags) != 0 {
+		t.Errorf("Expected the failed file to be removed from the index, got %v", snapshot.Tags)
+	}
+	if len(snapshot.Errors) != 2 || snapshot.Errors[0].Path != "a.md" || snapshot.Errors[1].Path != "c.md" {
+		t.Errorf("Expected errors for a.md and c.md, got %v", snapshot.Errors)
+	}
+
+	snapshot = store.UpdateFile("c.md", internal.Note{Path: "c.md"})
+	if len(snapshot.Errors) != 1 || snapshot.Errors[0].Path != "a.md" {
+		t.Errorf("Expected the error of c.md to be cleared, got %v", snapshot.Errors)
+	}
+
+	snapshot = store.RemoveTree("a.md")
+	if len(snapshot.Errors) != 0 {
+		t.Errorf("Expected the error of a removed file to be cleared, got %v", snapshot.Errors)
+	}
+}
+
+func TestLinksFollowUpdates(t *testing.T) {
+	store := index.NewStore()
+	first := store.Replace(map[string]internal.Note{
+		"a.md": {Path: "a.md"},
+		"b.md": {Path: "b.md"},
+	}, nil)
+	second := store.UpdateFile("a.md", internal.Note{Path: "a.md", Links: []internal.Link{{Kind: internal.LinkWiki, Target: "b"}}})
+
+	// Readers may ask for the links of a snapshot concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			second.Links()
+		}()
+	}
+	wg.Wait()
+
+	if backlinks := second.Links().Backlinks["b.md"]; len(backlinks) != 1 || backlinks[0].Source != "a.md" {
+		t.Errorf("Expected a backlink from a.md, got %v", backlinks)
+	}
+	if backlinks := first.Links().Backlinks["b.md"]; len(backlinks) != 0 {
+		t.Errorf("Expected the earlier snapshot to keep its links, got %v", backlinks)
+	}
+
+	third := store.RemoveFile("b.md")
+	if links := third.Links().Links["a.md"]; len(links) != 1 || links[0].Status != index.LinkUnresolved {
+		t.Errorf("Expected the link to b.md to be unresolved once b.md is removed, got %v", links)
+	}
+}
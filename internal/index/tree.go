@@ -0,0 +1,144 @@
+package index
+
+import (
+	"strings"
+
+	"github.com/ozcankasal/zettelo/internal"
+)
+
+// TagNode is a node of the tag tree. Nested tags such as #project/alpha/backend are
+// children of #project/alpha, which is a child of #project.
+type TagNode struct {
+	// Name is the last segment of the tag, such as "backend".
+	Name string `json:"name"`
+	// Tag is the full tag, such as "#project/alpha/backend".
+	Tag string `json:"tag"`
+	// Count is the number of values of the tag itself; Total includes its descendants.
+	Count    int        `json:"count"`
+	Total    int        `json:"total"`
+	Children []*TagNode `json:"children,omitempty"`
+}
+
+/*
+BuildTree arranges a tag list as a tree by splitting tags at '/'. Ancestors that are not
+used on their own are included with a count of 0. Empty segments are ignored, so #a//b
+is a child of #a.
+
+Usage:
+
+	tree := index.BuildTree(snapshot.Tags)
+
+Parameters:
+
+	tags (internal.TagList): the tag list, sorted as by sort.Sort
+
+Returns:
+
+	([]*TagNode): the top-level tags, with their totals rolled up from their descendants
+*/
+func BuildTree(tags internal.TagList) []*TagNode {
+	root := &TagNode{Children: []*TagNode{}}
+	nodes := make(map[string]*TagNode)
+	for _, tagged := range tags {
+		parent := root
+		path := ""
+		for _, segment := range tagSegments(tagged.Tag) {
+			if path == "" {
+				path = segment
+			} else {
+				path += "/" + segment
+			}
+			node, ok := nodes[path]
+			if !ok {
+				node = &TagNode{Name: strings.TrimPrefix(segment, "#"), Tag: path}
+				nodes[path] = node
+				parent.Children = append(parent.Children, node)
+			}
+			parent = node
+		}
+		parent.Count += len(tagged.Values)
+	}
+	for _, node := range root.Children {
+		rollUp(node)
+	}
+	return root.Children
+}
+
+// tagSegments splits a tag at '/', leaving out empty segments.
+func tagSegments(tag string) []string {
+	var segments []string
+	for _, segment := range strings.Split(tag, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// rollUp sets the totals of a node and its descendants.
+func rollUp(node *TagNode) int {
+	node.Total = node.Count
+	for _, child := range node.Children {
+		node.Total += rollUp(child)
+	}
+	return node.Total
+}
+
+/*
+IsDescendant reports whether a tag is nested below another, such as #project/alpha
+below #project.
+
+Usage:
+
+	if index.IsDescendant("#project/alpha", "#project") {
+		// ...
+	}
+
+Parameters:
+
+	tag (string): the tag to check
+	ancestor (string): the possible ancestor
+
+Returns:
+
+	(bool): true if tag is a descendant of ancestor
+*/
+func IsDescendant(tag, ancestor string) bool {
+	segments, ancestors := tagSegments(tag), tagSegments(ancestor)
+	if len(ancestors) == 0 || len(segments) <= len(ancestors) {
+		return false
+	}
+	for i, segment := range ancestors {
+		if segments[i] != segment {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+SelectTags returns the entries of a tag list for a tag and, optionally, its descendants.
+
+Usage:
+
+	tagList := index.SelectTags(snapshot.Tags, "#project", true)
+
+Parameters:
+
+	tags (internal.TagList): the tag list
+	tag (string): the tag to select
+	descendants (bool): whether to include the tags nested below tag
+
+Returns:
+
+	(internal.TagList): the selected entries, in the order of tags
+*/
+func SelectTags(tags internal.TagList, tag string, descendants bool) internal.TagList {
+	result := internal.TagList{}
+	for _, tagged := range tags {
+		if tagged.Tag == tag || descendants && IsDescendant(tagged.Tag, tag) {
+			result = append(result, tagged)
+		}
+	}
+	return result
+}
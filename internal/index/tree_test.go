@@ -0,0 +1,75 @@
+package index_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/index"
+)
+
+func values(n int) []internal.ResultValue {
+	v := make([]internal.ResultValue, n)
+	for i := range v {
+		v[i] = internal.ResultValue{FilePath: "a.md"}
+	}
+	return v
+}
+
+func TestTagListSortsNestedTagsAfterParent(t *testing.T) {
+	tags := internal.TagList{{Tag: "#project-x"}, {Tag: "#project/alpha"}, {Tag: "#project"}, {Tag: "#project/alpha/backend"}, {Tag: "#idea"}}
+	sort.Sort(tags)
+
+	var got []string
+	for _, tag := range tags {
+		got = append(got, tag.Tag)
+	}
+	expected := []string{"#idea", "#project", "#project/alpha", "#project/alpha/backend", "#project-x"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	tags := internal.TagList{
+		{Tag: "#idea", Values: values(1)},
+		{Tag: "#project/alpha", Values: values(2)},
+		{Tag: "#project/alpha/backend", Values: values(3)},
+		{Tag: "#project/beta", Values: values(1)},
+	}
+
+	expected := []*index.TagNode{
+		{Name: "idea", Tag: "#idea", Count: 1, Total: 1},
+		{Name: "project", Tag: "#project", Count: 0, Total: 6, Children: []*index.TagNode{
+			{Name: "alpha", Tag: "#project/alpha", Count: 2, Total: 5, Children: []*index.TagNode{
+				{Name: "backend", Tag: "#project/alpha/backend", Count: 3, Total: 3},
+			}},
+			{Name: "beta", Tag: "#project/beta", Count: 1, Total: 1},
+		}},
+	}
+	if tree := index.BuildTree(tags); !reflect.DeepEqual(tree, expected) {
+		t.Errorf("Unexpected tree %+v", tree)
+	}
+	if tree := index.BuildTree(nil); tree == nil || len(tree) != 0 {
+		t.Errorf("Expected an empty tree, got %v", tree)
+	}
+}
+
+func TestSelectTags(t *testing.T) {
+	tags := internal.TagList{{Tag: "#project"}, {Tag: "#project/alpha"}, {Tag: "#project-x"}, {Tag: "#projects"}}
+
+	var got []string
+	for _, tag := range index.SelectTags(tags, "#project", true) {
+		got = append(got, tag.Tag)
+	}
+	if expected := []string{"#project", "#project/alpha"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if got := index.SelectTags(tags, "#project", false); len(got) != 1 {
+		t.Errorf("Expected only the tag itself, got %v", got)
+	}
+	if index.IsDescendant("#project", "#project") {
+		t.Errorf("Expected a tag not to be its own descendant")
+	}
+}
@@ -0,0 +1,250 @@
+/*
+Package scanner finds the markdown files of a vault and reads their front matter and
+tagged lines.
+
+A file that cannot be read does not stop the scan: the failure is recorded in the
+result and every other note is still indexed.
+*/
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/cache"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+// Kinds of scan errors.
+const (
+	ErrorNotFound   = "not_found"
+	ErrorPermission = "permission"
+	ErrorRead       = "read"
+	// ErrorHeader is a note whose front matter could not be decoded.
+	ErrorHeader = "header"
+)
+
+// Result is the outcome of scanning a set of folders.
+type Result struct {
+	// Paths lists every markdown file that was found, in walk order.
+	Paths []string
+	// Files holds the note of every file that was read successfully.
+	Files map[string]internal.Note
+	// Errors lists the files and folders that could not be read, sorted by path.
+	Errors []internal.ScanError
+}
+
+// Options tune a scan.
+type Options struct {
+	// Workers is the number of files parsed concurrently; 0 uses one worker per CPU.
+	Workers int
+	// Cache holds the parse results of an earlier run; may be nil.
+	Cache *cache.Cache
+}
+
+/*
+Scan walks the folders and extracts the tagged lines of every markdown file using a
+bounded pool of workers. The result does not depend on the number of workers. When a
+cache is given, only the notes that changed since they were cached are parsed, and the
+cache is updated with the new results.
+
+Usage:
+
+	result, err := scanner.Scan(ctx, config.App.Folders, *config, scanner.Options{Cache: c})
+
+Parameters:
+
+	ctx (context.Context): cancels the scan
+	folders ([]string): the folders to scan recursively
+	config (internal.Config): the configuration used to extract tags
+	opts (Options): the number of workers and the cache
+
+Returns:
+
+	(*Result): the notes by file and the errors that occurred
+	(error): the context error if the scan was cancelled
+*/
+func Scan(ctx context.Context, folders []string, config internal.Config, opts Options) (*Result, error) {
+	files, scanErrors, err := listFiles(ctx, folders)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	// Every worker writes to its own slots, so no locking is needed.
+	notes := make([]internal.Note, len(files))
+	errs := make([]error, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				notes[i], errs[i] = parseCached(files[i], config, opts.Cache)
+			}
+		}()
+	}
+
+feed:
+	for i := range files {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Paths:  files,
+		Files:  make(map[string]internal.Note, len(files)),
+		Errors: scanErrors,
+	}
+	for i, file := range files {
+		if errs[i] != nil {
+			result.Errors = append(result.Errors, NewError(file, errs[i]))
+			continue
+		}
+		result.Files[file] = notes[i]
+	}
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Path < result.Errors[j].Path })
+
+	if opts.Cache != nil {
+		// Forget notes that no longer exist.
+		opts.Cache.Prune(func(path string) bool {
+			_, ok := result.Files[path]
+			return ok
+		})
+	}
+	return result, nil
+}
+
+// parseCached returns the cached note when its size and modification time or its content
+// are unchanged, and parses it otherwise.
+func parseCached(path string, config internal.Config, c *cache.Cache) (internal.Note, error) {
+	if c == nil {
+		return ParseFile(path, config)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return internal.Note{}, err
+	}
+	if note, ok := c.Lookup(path, info); ok {
+		return note, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return internal.Note{}, err
+	}
+	hash := cache.Hash(data)
+	if note, ok := c.LookupHash(path, info, hash); ok {
+		return note, nil
+	}
+
+	note := utils.ParseNote(path, data, config)
+	c.Put(path, info, hash, note)
+	return note, nil
+}
+
+/*
+ListFiles returns the markdown files below the folders. Folders that cannot be read are
+reported as errors and skipped.
+
+Usage:
+
+	files, scanErrors := scanner.ListFiles(config.App.Folders)
+
+Parameters:
+
+	folders ([]string): the folders to scan recursively
+
+Returns:
+
+	([]string): the paths of the markdown files
+	([]internal.ScanError): the paths that could not be read
+*/
+func ListFiles(folders []string) ([]string, []internal.ScanError) {
+	files, scanErrors, _ := listFiles(context.Background(), folders)
+	return files, scanErrors
+}
+
+func listFiles(ctx context.Context, folders []string) ([]string, []internal.ScanError, error) {
+	var files []string
+	var scanErrors []internal.ScanError
+	for _, folder := range folders {
+		err := filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				scanErrors = append(scanErrors, NewError(path, err))
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() && IsMarkdown(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return files, scanErrors, nil
+}
+
+// ParseFile reads a markdown file and parses its front matter and tagged lines.
+func ParseFile(path string, config internal.Config) (internal.Note, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return internal.Note{}, err
+	}
+	return utils.ParseNote(path, data, config), nil
+}
+
+// IsMarkdown reports whether the path names a markdown file.
+func IsMarkdown(path string) bool {
+	return filepath.Ext(path) == ".md"
+}
+
+// NewError describes the failure to read path.
+func NewError(path string, err error) internal.ScanError {
+	kind := ErrorRead
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		kind = ErrorNotFound
+	case errors.Is(err, fs.ErrPermission):
+		kind = ErrorPermission
+	}
+	return internal.ScanError{
+		Path:    path,
+		Kind:    kind,
+		Message: err.Error(),
+		Time:    time.Now(),
+	}
+}
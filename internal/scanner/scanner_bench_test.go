@@ -0,0 +1,86 @@
+package scanner_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/scanner"
+)
+
+// benchNotes is the size of the synthetic vault. Set ZETTELO_BENCH_NOTES to change it.
+const benchNotes = 50000
+
+var (
+	benchVaultOnce sync.Once
+	benchVault     string
+	benchVaultErr  error
+)
+
+// syntheticVault creates a vault shared by all benchmarks of the run.
+func syntheticVault(b *testing.B) string {
+	b.Helper()
+	benchVaultOnce.Do(func() {
+		notes := benchNotes
+		if n, err := strconv.Atoi(os.Getenv("ZETTELO_BENCH_NOTES")); err == nil && n > 0 {
+			notes = n
+		}
+		benchVault, benchVaultErr = os.MkdirTemp("", "zettelo-bench-")
+		if benchVaultErr != nil {
+			return
+		}
+		for i := 0; i < notes && benchVaultErr == nil; i++ {
+			dir := filepath.Join(benchVault, fmt.Sprintf("folder%03d", i%250))
+			if benchVaultErr = os.MkdirAll(dir, 0755); benchVaultErr != nil {
+				return
+			}
+			content := fmt.Sprintf("---\nid: %d\n---\n# Note %d\n\n"+
+				"Some text about #topic%d and #todo follow up\n\n"+
+				"- a bullet with #idea/%d in it\n- plain bullet\n\n"+
+				"A paragraph without tags that is long enough to matter for the parser.\n",
+				i, i, i%100, i%10)
+			benchVaultErr = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("note%d.md", i)), []byte(content), 0644)
+		}
+	})
+	if benchVaultErr != nil {
+		b.Fatal(benchVaultErr)
+	}
+	return benchVault
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if benchVault != "" {
+		os.RemoveAll(benchVault)
+	}
+	os.Exit(code)
+}
+
+func BenchmarkScan(b *testing.B) {
+	vault := syntheticVault(b)
+
+	workerCounts := []int{1, 2, 4}
+	if cpus := runtime.NumCPU(); cpus > 4 {
+		workerCounts = append(workerCounts, cpus)
+	}
+	for _, workers := range workerCounts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result, err := scanner.Scan(context.Background(), []string{vault}, internal.Config{}, scanner.Options{Workers: workers})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(result.Errors) > 0 {
+					b.Fatal(result.Errors[0].Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package scanner_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/cache"
+	"github.com/ozcankasal/zettelo/internal/scanner"
+)
+
+func TestScanRecordsErrorsAndKeepsHealthyNotes(t *testing.T) {
+	root := t.TempDir()
+	healthy := filepath.Join(root, "healthy.md")
+	if err := ioutil.WriteFile(healthy, []byte("#todo write tests\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "ignored.txt"), []byte("#todo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A dangling symlink is listed but cannot be read.
+	broken := filepath.Join(root, "broken.md")
+	if err := os.Symlink(filepath.Join(root, "missing.md"), broken); err != nil {
+		t.Fatal(err)
+	}
+	missingFolder := filepath.Join(root, "no-such-folder")
+
+	result, err := scanner.Scan(context.Background(), []string{root, missingFolder}, internal.Config{}, scanner.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Files) != 1 {
+		t.Fatalf("Expected only the healthy note to be indexed, got %v", result.Files)
+	}
+	if lines := result.Files[healthy].Lines; len(lines) != 1 || lines[0].Tag != "#todo" {
+		t.Errorf("Unexpected tagged lines for %s: %v", healthy, lines)
+	}
+
+	kinds := make(map[string]string)
+	for _, scanErr := range result.Errors {
+		kinds[scanErr.Path] = scanErr.Kind
+		if scanErr.Message == "" || scanErr.Time.IsZero() {
+			t.Errorf("Expected a message and a time in %+v", scanErr)
+		}
+	}
+	expected := map[string]string{
+		broken:        scanner.ErrorNotFound,
+		missingFolder: scanner.ErrorNotFound,
+	}
+	if len(kinds) != len(expected) {
+		t.Fatalf("Expected errors %v, got %v", expected, kinds)
+	}
+	for path, kind := range expected {
+		if kinds[path] != kind {
+			t.Errorf("Expected %s error for %s, got %q", kind, path, kinds[path])
+		}
+	}
+}
+
+func TestScanUsesCache(t *testing.T) {
+	root := t.TempDir()
+	note := filepath.Join(root, "note.md")
+	if err := ioutil.WriteFile(note, []byte("#todo one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(t.TempDir(), "cache.gob")
+	key := cache.Key(internal.Config{})
+
+	c := cache.Load(cachePath, key)
+	if _, err := scanner.Scan(context.Background(), []string{root}, internal.Config{}, scanner.Options{Cache: c}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same size and modification time: the cached result is used without reading the file.
+	if err := ioutil.WriteFile(note, []byte("#idea two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(note, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	result, err := scanner.Scan(context.Background(), []string{root}, internal.Config{}, scanner.Options{Cache: cache.Load(cachePath, key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := result.Files[note].Lines; len(lines) != 1 || lines[0].Tag != "#todo" {
+		t.Errorf("Expected the cached #todo, got %v", lines)
+	}
+
+	// A new modification time makes the scanner parse the note again.
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(note, later, later); err != nil {
+		t.Fatal(err)
+	}
+	result, err = scanner.Scan(context.Background(), []string{root}, internal.Config{}, scanner.Options{Cache: cache.Load(cachePath, key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := result.Files[note].Lines; len(lines) != 1 || lines[0].Tag != "#idea" {
+		t.Errorf("Expected the changed note to be parsed again, got %v", lines)
+	}
+}
+
+func TestScanIsDeterministic(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 200; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%d", i%7))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := fmt.Sprintf("#tag%d note %d\n#todo item %d\n", i%13, i, i)
+		if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("n%d.md", i)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serial, err := scanner.Scan(context.Background(), []string{root}, internal.Config{}, scanner.Options{Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel, err := scanner.Scan(context.Background(), []string{root}, internal.Config{}, scanner.Options{Workers: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serial.Files) != 200 || !reflect.DeepEqual(serial, parallel) {
+		t.Errorf("Expected the same result with 1 and 8 workers")
+	}
+}
+
+func TestScanCancelled(t *testing.T) {
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "note.md"), []byte("#todo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := scanner.Scan(ctx, []string{root}, internal.Config{}, scanner.Options{}); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
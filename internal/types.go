@@ -1,5 +1,7 @@
 package internal
 
+import "time"
+
 type TaggedLine struct {
 	Tag    string        `json:"tag"`
 	Values []ResultValue `json:"values"`
@@ -8,18 +10,144 @@ type TaggedLine struct {
 type ResultValue struct {
 	FilePath string `json:"file_path"`
 	Line     string `json:"line"`
+	// Body holds the lines that continue the value: indented lines and nested list
+	// items below the tag, or the section of a tagged heading. EndLine is the last of them.
+	Body    []string `json:"body,omitempty"`
+	EndLine int      `json:"end_line,omitempty"`
+	// LineNumber and Column locate the tag in the file; both are 1-based.
+	LineNumber int `json:"line_number,omitempty"`
+	Column     int `json:"column,omitempty"`
+	// Heading is the path of the headings enclosing the tag, such as "Title > Section".
+	Heading string `json:"heading,omitempty"`
+	// NoteID is the id from the header of the file.
+	NoteID string `json:"note_id,omitempty"`
+	// Attributes are the structured tags of the line, such as #due: 2026-11-01.
+	Attributes []Attribute `json:"attributes,omitempty"`
+	// Origin tells where the tag was found, OriginHashtag or OriginFrontMatter.
+	Origin string `json:"origin"`
 }
 
-type Config struct {
-	Web struct {
-		Port int    `yaml:"port"`
-		Host string `yaml:"host"`
-	} `yaml:"web"`
+// Origins of a tagged value.
+const (
+	// OriginHashtag is a hashtag in the text of a note.
+	OriginHashtag = "hashtag"
+	// OriginFrontMatter is an entry of the tags field of the front matter.
+	OriginFrontMatter = "front_matter"
+)
+
+// Attribute is a key and typed value parsed from a structured tag.
+type Attribute struct {
+	Key string `json:"key"`
+	// Type is "date", "number", "duration" or "string"; Value is the text of the value.
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	// The typed value is in the field matching the type.
+	Time     *time.Time    `json:"time,omitempty"`
+	Number   float64       `json:"number,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Note is an indexed markdown file: the fields of its front matter and its tagged lines.
+type Note struct {
+	Path string `json:"path"`
+	ID   string `json:"id,omitempty"`
+	// Title is the title of the front matter or else the first level 1 heading.
+	Title   string `json:"title,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Project string `json:"project,omitempty"`
+	// Tags are the canonical tags listed in the tags field of the front matter.
+	Tags []string `json:"tags,omitempty"`
+	// Aliases are other names of the note that wiki links may use.
+	Aliases []string `json:"aliases,omitempty"`
+	// Links are the links from the note to other notes, in document order.
+	Links []Link `json:"links,omitempty"`
+	// FrontMatterError tells why the front matter could not be read; the tagged lines
+	// are indexed regardless.
+	FrontMatterError string `json:"front_matter_error,omitempty"`
+	// Lines are the tagged lines of the note.
+	Lines TagList `json:"-"`
+}
+
+// Kinds of links.
+const (
+	// LinkWiki is a link such as [[Title]], [[id]], [[note|alias]] or [[note#heading]].
+	LinkWiki = "wiki"
+	// LinkMarkdown is a markdown link with a relative destination, such as [text](other.md).
+	LinkMarkdown = "markdown"
+)
+
+// Link is a link from a note to another note, as written in the note.
+type Link struct {
+	Kind string `json:"kind"`
+	// Target names the linked note: an ID, file name, title or alias for wiki links and a
+	// path relative to the note for markdown links. It is empty for wiki links within the
+	// note, like [[#heading]]; markdown links within the note, like [x](#heading), are not
+	// extracted.
+	Target string `json:"target"`
+	// Heading is the section of the target the link points to, if any.
+	Heading string `json:"heading,omitempty"`
+	// Alias is the text shown for a wiki link instead of the target.
+	Alias string `json:"alias,omitempty"`
+	// LineNumber and Column locate the link in the note; both are 1-based.
+	LineNumber int `json:"line_number"`
+	Column     int `json:"column"`
+}
+
+// ScanError records a file or folder that could not be scanned.
+type ScanError struct {
+	Path    string    `json:"path"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"error"`
+	Time    time.Time `json:"time"`
+}
+
+type WebConfig struct {
+	Port int    `yaml:"port"`
+	Host string `yaml:"host"`
+	// EditorURL is the link opened for a tag in the web UI. {path}, {line} and
+	// {column} are replaced with the location of the tag.
+	EditorURL string `yaml:"editor_url"`
+}
+
+// TagMapper maps tags to their canonical form.
+type TagMapper interface {
+	// CanonicalTag returns the canonical form of a tag and whether a mapping rule matched.
+	CanonicalTag(tag string) (string, bool)
+}
 
-	App struct {
-		TagMappings map[string]string `yaml:"tag_mappings"`
-		Folders     []string          `yaml:"folders"`
-	} `yaml:"app"`
+type AppConfig struct {
+	TagMappings map[string]string `yaml:"tag_mappings"`
+	// TagSynonyms maps canonical tags to lists of the tags and patterns mapped to them.
+	TagSynonyms map[string][]string `yaml:"tag_synonyms"`
+	// FoldCase makes tags that differ only in case the same tag.
+	FoldCase bool `yaml:"fold_case"`
+	// UnicodeNormalization is the Unicode normal form of tags: NFC (the default), NFKC or none.
+	UnicodeNormalization string `yaml:"unicode_normalization"`
+	// Mapper holds the compiled tag rules; ParseConfig sets it.
+	Mapper  TagMapper `yaml:"-"`
+	Folders []string  `yaml:"folders"`
+	// Debounce is how long a file must be quiet before a change is indexed.
+	Debounce time.Duration `yaml:"debounce"`
+	// AssignIDs makes the server add an id to notes created or changed without one.
+	AssignIDs bool `yaml:"assign_ids"`
+	// IDScheme is the format of new note IDs: uuid4 (the default), uuid7, ulid,
+	// timestamp or folgezettel.
+	IDScheme string `yaml:"id_scheme"`
+	// IDFilenamePrefix puts the ID of a note in front of its file name and reads the ID of
+	// notes without one in their front matter from there.
+	IDFilenamePrefix bool `yaml:"id_filename_prefix"`
+	// StructuredTags parses #key: value, #key:value and #key(value) into the tag #key
+	// with a typed attribute.
+	StructuredTags bool `yaml:"structured_tags"`
+	// SectionTags makes a tag on a heading cover the whole section below the heading.
+	SectionTags bool `yaml:"section_tags"`
+	// Cache is the file holding cached parse results; empty selects ~/.zettelo/cache.
+	Cache string `yaml:"cache"`
+}
+
+type Config struct {
+	Web WebConfig `yaml:"web"`
+	App AppConfig `yaml:"app"`
 }
 
 type TagList []TaggedLine
@@ -28,8 +156,36 @@ func (t TagList) Len() int {
 	return len(t)
 }
 
+// Less orders tags lexically, except that '/' sorts before every other character so
+// that nested tags such as #project/alpha directly follow their parent.
 func (t TagList) Less(i, j int) bool {
-	return t[i].Tag < t[j].Tag
+	return CompareTags(t[i].Tag, t[j].Tag) < 0
+}
+
+// CompareTags compares two tags in the order of TagList.
+func CompareTags(a, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		if a[i] == '/' {
+			return -1
+		}
+		if b[i] == '/' {
+			return 1
+		}
+		if a[i] < b[i] {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
 }
 
 func (t TagList) Swap(i, j int) {
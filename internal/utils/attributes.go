@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ozcankasal/zettelo/internal"
+)
+
+// Types of attribute values.
+const (
+	AttributeDate     = "date"
+	AttributeNumber   = "number"
+	AttributeDuration = "duration"
+	AttributeString   = "string"
+)
+
+// attributeRegex matches a structured tag at the start of a string: #key(value),
+// #key:value or #key: followed by the value.
+var attributeRegex = regexp.MustCompile(`^#([^\s#:()]+)(?:\(([^)]*)\)|:(\S+)|:)`)
+
+// dateLayouts are the accepted formats of date values.
+var dateLayouts = []string{"2006-01-02", "2006-01-02T15:04", time.RFC3339}
+
+// durationRegex matches one component of a duration such as 1w, 2d, 3h or 30m.
+var durationRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)(ms|w|d|h|m|s)`)
+
+var durationUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+/*
+ParseAttribute returns the typed attribute for a key and its value text. The value is a
+date if it has the form 2006-01-02, 2006-01-02T15:04 or RFC 3339, a number if it is a
+decimal number, a duration if it is made of components like 1w, 2d, 3h, 30m, 10s or
+500ms, and a string otherwise.
+
+Usage:
+
+	attr := ParseAttribute("due", "2026-11-01")
+
+Parameters:
+
+	key (string): the attribute name
+	value (string): the value text
+
+Returns:
+
+	(internal.Attribute): the attribute with its type and typed value
+*/
+func ParseAttribute(key, value string) internal.Attribute {
+	attr := internal.Attribute{Key: key, Type: AttributeString, Value: value}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			attr.Type = AttributeDate
+			attr.Time = &t
+			return attr
+		}
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil && strings.IndexFunc(value, isLetter) < 0 {
+		attr.Type = AttributeNumber
+		attr.Number = n
+		return attr
+	}
+	if d, ok := parseDuration(value); ok {
+		attr.Type = AttributeDuration
+		attr.Duration = d
+		return attr
+	}
+	return attr
+}
+
+// isLetter excludes spellings like "Inf", "NaN" and "1e3" from numbers.
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+// parseDuration parses durations made of components like 1w2d or 1h30m.
+func parseDuration(value string) (time.Duration, bool) {
+	matches := durationRegex.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	var total time.Duration
+	pos := 0
+	for _, m := range matches {
+		if m[0] != pos {
+			return 0, false
+		}
+		n, err := strconv.ParseFloat(value[m[2]:m[3]], 64)
+		if err != nil {
+			return 0, false
+		}
+		total += time.Duration(n * float64(durationUnits[value[m[4]:m[5]]]))
+		pos = m[1]
+	}
+	return total, pos == len(value)
+}
+
+/*
+CompareAttributes orders two attributes by value. Attributes of the same type compare by
+their typed value; otherwise, and for strings, the value texts are compared.
+
+Usage:
+
+	if CompareAttributes(a, b) < 0 {
+		// a sorts before b
+	}
+
+Parameters:
+
+	a (internal.Attribute): the first attribute
+	b (internal.Attribute): the second attribute
+
+Returns:
+
+	(int): -1 if a sorts before b, 1 if after and 0 if they are equal
+*/
+func CompareAttributes(a, b internal.Attribute) int {
+	if a.Type == b.Type {
+		switch a.Type {
+		case AttributeDate:
+			return a.Time.Compare(*b.Time)
+		case AttributeNumber:
+			return compareNumbers(a.Number, b.Number)
+		case AttributeDuration:
+			return compareNumbers(float64(a.Duration), float64(b.Duration))
+		}
+	}
+	return strings.Compare(a.Value, b.Value)
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// FindAttribute returns the attribute of a value with the given key.
+func FindAttribute(value internal.ResultValue, key string) (internal.Attribute, bool) {
+	for _, attr := range value.Attributes {
+		if attr.Key == key {
+			return attr, true
+		}
+	}
+	return internal.Attribute{}, false
+}
+
+// AttributeFilter selects values by one of their attributes.
+type AttributeFilter struct {
+	Key string
+	// Op is one of =, !=, <, <=, > and >=, or empty to only require the attribute.
+	Op    string
+	Value internal.Attribute
+}
+
+// filterRegex splits a filter expression into key, operator and value.
+var filterRegex = regexp.MustCompile(`^#?([^\s=!<>]+)\s*(?:(=|!=|<=|>=|<|>)\s*(.*))?$`)
+
+/*
+ParseAttributeFilter parses a filter expression such as "due<2026-12-01",
+"priority=high" or "weight". The value is typed like attribute values, so dates,
+numbers and durations compare by value.
+
+Usage:
+
+	filter, err := ParseAttributeFilter("due<2026-12-01")
+
+Parameters:
+
+	expr (string): the filter expression
+
+Returns:
+
+	(AttributeFilter): the parsed filter
+	(error): if the expression is not valid
+*/
+func ParseAttributeFilter(expr string) (AttributeFilter, error) {
+	m := filterRegex.FindStringSubmatch(strings.TrimSpace(expr))
+	if m == nil {
+		return AttributeFilter{}, fmt.Errorf("invalid filter %q", expr)
+	}
+	return AttributeFilter{Key: m[1], Op: m[2], Value: ParseAttribute(m[1], strings.TrimSpace(m[3]))}, nil
+}
+
+// Match reports whether the value has the attribute and it satisfies the filter.
+func (f AttributeFilter) Match(value internal.ResultValue) bool {
+	attr, ok := FindAttribute(value, f.Key)
+	if !ok {
+		return false
+	}
+	if f.Op == "" {
+		return true
+	}
+	c := CompareAttributes(attr, f.Value)
+	switch f.Op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
@@ -0,0 +1,78 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func TestParseAttribute(t *testing.T) {
+	testCases := []struct {
+		value    string
+		typ      string
+		number   float64
+		duration time.Duration
+	}{
+		{value: "2026-11-01", typ: utils.AttributeDate},
+		{value: "2026-11-01T09:30", typ: utils.AttributeDate},
+		{value: "72", typ: utils.AttributeNumber, number: 72},
+		{value: "-1.5", typ: utils.AttributeNumber, number: -1.5},
+		{value: "1h30m", typ: utils.AttributeDuration, duration: 90 * time.Minute},
+		{value: "2d", typ: utils.AttributeDuration, duration: 48 * time.Hour},
+		{value: "1w1d", typ: utils.AttributeDuration, duration: 8 * 24 * time.Hour},
+		{value: "high", typ: utils.AttributeString},
+		{value: "NaN", typ: utils.AttributeString},
+		{value: "2d later", typ: utils.AttributeString},
+	}
+
+	for _, testCase := range testCases {
+		attr := utils.ParseAttribute("key", testCase.value)
+		if attr.Type != testCase.typ || attr.Number != testCase.number || attr.Duration != testCase.duration {
+			t.Errorf("%q: expected %s %v %v, got %s %v %v", testCase.value, testCase.typ, testCase.number, testCase.duration, attr.Type, attr.Number, attr.Duration)
+		}
+		if attr.Key != "key" || attr.Value != testCase.value {
+			t.Errorf("%q: unexpected key or value in %+v", testCase.value, attr)
+		}
+		if (attr.Time != nil) != (testCase.typ == utils.AttributeDate) {
+			t.Errorf("%q: unexpected time %v", testCase.value, attr.Time)
+		}
+	}
+}
+
+func TestAttributeFilter(t *testing.T) {
+	value := internal.ResultValue{Attributes: []internal.Attribute{
+		utils.ParseAttribute("due", "2026-11-01"),
+		utils.ParseAttribute("weight", "72"),
+		utils.ParseAttribute("priority", "high"),
+	}}
+
+	testCases := []struct {
+		expr  string
+		match bool
+	}{
+		{expr: "due<2026-12-01", match: true},
+		{expr: "due>=2026-11-02", match: false},
+		{expr: "weight > 9", match: true},
+		{expr: "weight=72.0", match: true},
+		{expr: "priority=high", match: true},
+		{expr: "#priority!=high", match: false},
+		{expr: "weight", match: true},
+		{expr: "estimate", match: false},
+	}
+
+	for _, testCase := range testCases {
+		filter, err := utils.ParseAttributeFilter(testCase.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", testCase.expr, err)
+		}
+		if match := filter.Match(value); match != testCase.match {
+			t.Errorf("%q: expected %v, got %v", testCase.expr, testCase.match, match)
+		}
+	}
+
+	if _, err := utils.ParseAttributeFilter("<3"); err == nil {
+		t.Errorf("Expected an error for a filter without a key")
+	}
+}
@@ -0,0 +1,191 @@
+package utils_test
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name:     "equal",
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			expected: "",
+		},
+		{
+			name: "insert at start",
+			old:  "# Title\ntext\n",
+			new:  "---\nid: 1\n---\n# Title\ntext\n",
+			expected: "--- a/note.md\n+++ b/note.md\n" +
+				"@@ -1,2 +1,5 @@\n" +
+				"+---\n+id: 1\n+---\n # Title\n text\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new:  "1\nx\n3\n4\n5\n6\n7\n8\n9\n10\ny\n12\n",
+			expected: "--- a/note.md\n+++ b/note.md\n" +
+				"@@ -1,5 +1,5 @@\n 1\n-2\n+x\n 3\n 4\n 5\n" +
+				"@@ -8,5 +8,5 @@\n 8\n 9\n 10\n-11\n+y\n 12\n",
+		},
+		{
+			name: "changes twice the context apart",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new:  "1\nx\n3\n4\n5\n6\n7\n8\ny\n10\n11\n12\n",
+			expected: "--- a/note.md\n+++ b/note.md\n" +
+				"@@ -1,12 +1,12 @@\n 1\n-2\n+x\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+y\n 10\n 11\n 12\n",
+		},
+		{
+			name: "changes one line more than twice the context apart",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n",
+			new:  "1\nx\n3\n4\n5\n6\n7\n8\n9\ny\n11\n12\n13\n",
+			expected: "--- a/note.md\n+++ b/note.md\n" +
+				"@@ -1,5 +1,5 @@\n 1\n-2\n+x\n 3\n 4\n 5\n" +
+				"@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+y\n 11\n 12\n 13\n",
+		},
+		{
+			name: "missing newline",
+			old:  "a",
+			new:  "b",
+			expected: "--- a/note.md\n+++ b/note.md\n" +
+				"@@ -1,1 +1,1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := utils.UnifiedDiff("a/note.md", "b/note.md", []byte(tc.old), []byte(tc.new))
+			if actual != tc.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffLargeNote(t *testing.T) {
+	var body strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&body, "line %d #tag\n", i)
+	}
+	old := "# Title\n" + body.String() + "end\n"
+	new := "---\nid: 1\n---\n# Title\n" + body.String() + "the end\n"
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	actual := utils.UnifiedDiff("a/note.md", "b/note.md", []byte(old), []byte(new))
+	runtime.ReadMemStats(&after)
+
+	expected := "--- a/note.md\n+++ b/note.md\n" +
+		"@@ -1,3 +1,6 @@\n+---\n+id: 1\n+---\n # Title\n line 0 #tag\n line 1 #tag\n" +
+		"@@ -19999,4 +20002,4 @@\n line 19997 #tag\n line 19998 #tag\n line 19999 #tag\n-end\n+the end\n"
+	if actual != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, actual)
+	}
+	// A table of all pairs of lines would take gigabytes.
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("Expected the diff to allocate less than 64 MiB, got %d MiB", allocated>>20)
+	}
+}
+
+func TestUnifiedDiffIsMinimal(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, random.Intn(12))
+		for i := range lines {
+			lines[i] = strconv.Itoa(random.Intn(4)) + "\n"
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		diff := utils.UnifiedDiff("a", "b", []byte(strings.Join(a, "")), []byte(strings.Join(b, "")))
+
+		patched, edits, err := applyDiff(a, diff)
+		if err != nil {
+			t.Fatalf("%v in the diff of %q and %q:\n%s", err, a, b, diff)
+		}
+		if strings.Join(patched, "") != strings.Join(b, "") {
+			t.Fatalf("Applying the diff of %q and %q gave %q:\n%s", a, b, patched, diff)
+		}
+		if minimal := len(a) + len(b) - 2*lcsLength(a, b); edits != minimal {
+			t.Fatalf("Expected %d changed lines in the diff of %q and %q, got %d:\n%s", minimal, a, b, edits, diff)
+		}
+	}
+}
+
+// applyDiff applies a unified diff without missing newlines to the old lines and returns
+// the new lines and the number of added and removed lines.
+func applyDiff(old []string, diff string) ([]string, int, error) {
+	var result []string
+	next, edits := 0, 0
+	for _, line := range splitAfter(diff) {
+		switch {
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+		case strings.HasPrefix(line, "@@ "):
+			var oldStart, oldCount int
+			if _, err := fmt.Sscanf(line, "@@ -%d,%d", &oldStart, &oldCount); err != nil {
+				return nil, 0, err
+			}
+			if oldCount == 0 {
+				oldStart++
+			}
+			for ; next < oldStart-1; next++ {
+				result = append(result, old[next])
+			}
+		case line[0] == ' ' || line[0] == '-':
+			if next >= len(old) || old[next] != line[1:] {
+				return nil, 0, fmt.Errorf("line %d does not match %q", next+1, line)
+			}
+			if line[0] == ' ' {
+				result = append(result, old[next])
+			} else {
+				edits++
+			}
+			next++
+		case line[0] == '+':
+			result = append(result, line[1:])
+			edits++
+		default:
+			return nil, 0, fmt.Errorf("unexpected line %q", line)
+		}
+	}
+	return append(result, old[next:]...), edits, nil
+}
+
+func splitAfter(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	return lines[:len(lines)-1]
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return lcs[0][0]
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+/*
+WriteFile replaces the contents of a file without ever leaving it half written: the
+content goes to a temporary file in the same folder, which is then renamed over the file.
+A crash or a full disk leaves either the old or the new contents.
+
+Usage:
+
+	err := WriteFile("note.md", newContent, info.Mode().Perm())
+
+Parameters:
+
+	path (string): the file to write
+	content ([]byte): the new contents
+	mode (os.FileMode): the permissions of the file
+
+Returns:
+
+	(error): if the file could not be written, in which case it is left alone
+*/
+func WriteFile(path string, content []byte, mode os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,52 @@
+package utils_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "note.md")
+	if err := ioutil.WriteFile(path, []byte("# Old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := utils.WriteFile(path, []byte("# New\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "# New\n" {
+		t.Errorf("Expected the new contents, got %q", content)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %o", info.Mode().Perm())
+	}
+
+	// No temporary file is left behind.
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the note in the folder, got %d files", len(entries))
+	}
+}
+
+func TestWriteFileMissingFolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "note.md")
+	if err := utils.WriteFile(path, []byte("# New\n"), 0644); err == nil {
+		t.Error("Expected an error for a missing folder")
+	}
+}
@@ -1,16 +1,23 @@
 package utils
 
 import (
-	"bufio"
 	"bytes"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/frontmatter"
 )
 
+// ParserVersion identifies the behaviour of ExtractTaggedLines. It must be increased
+// whenever the extracted lines change for the same input, so that cached parse results
+// are discarded.
+const ParserVersion = 8
+
 /*
-MapTagToCanonicalType maps a tag to its canonical type.
+MapTagToCanonicalType maps a tag to its canonical type using the tag_mappings and
+tag_synonyms rules of the configuration, see NewTagMapper.
 
 Usage:
 
@@ -27,13 +34,11 @@ Returns:
 	(string): the canonical type of the tag, or an empty string if the tag is not mapped to a canonical type
 */
 func MapTagToCanonicalType(tag string, config internal.Config) string {
-	for tagKey, tagValue := range config.App.TagMappings {
-		if tag == tagKey {
-			return tagValue
-		}
+	canonical, ok := tagMapper(config).CanonicalTag(tag)
+	if !ok {
+		return ""
 	}
-
-	return ""
+	return canonical
 }
 
 /*
@@ -53,17 +58,165 @@ Returns:
 	(string): the line without hashtags
 */
 func RemoveHashtagsFromLine(line string) string {
-	return regexp.MustCompile(`(?:^|\s)(#[^\s]+)`).ReplaceAllString(line, "")
+	return hashtagRegex.ReplaceAllString(line, "")
+}
+
+// The matchers are compiled once; they are safe for concurrent use.
+var (
+	hashtagRegex = regexp.MustCompile(`(?:^|\s)(#[^\s]+)`)
+	tagRegex     = regexp.MustCompile(`(?:^|\s)(#[^\s#][^\s]*)`)
+)
+
+// lineTag is a tag found on a line.
+type lineTag struct {
+	// text is the tag including the whitespace preceding it.
+	text string
+	// start is the byte position of the '#' in the line.
+	start int
+}
+
+// extractTagsFromLine returns the tags of a line. Only tags whose '#' lies in prose are
+// returned; offset is the position of the line in the document the mask was computed for.
+func extractTagsFromLine(line string, offset int, prose []bool) []lineTag {
+	if strings.IndexByte(line, '#') < 0 {
+		return nil
+	}
+	var tags []lineTag
+	for _, m := range tagRegex.FindAllStringIndex(line, -1) {
+		t := line[m[0]:m[1]]
+		start := m[0] + strings.IndexByte(t, '#')
+		if prose[offset+start] {
+			tags = append(tags, lineTag{text: t, start: start})
+		}
+	}
+	return tags
+}
+
+// listItemRegex matches the marker of a bullet or ordered list item.
+var listItemRegex = regexp.MustCompile(`^[ \t]*(?:[-*+]|\d{1,9}[.)])(?:[ \t]|$)`)
+
+// valueRef locates a value in the result of ExtractTaggedLines.
+type valueRef struct {
+	tag, value int
+}
+
+// block collects the lines that continue the values of a tagged line.
+type block struct {
+	refs []valueRef
+	// indent is the indentation of the tagged line; indented lines below it continue it.
+	indent int
+	// list is set when the tagged line is a list item, whose nested items may be
+	// separated by blank lines.
+	list bool
+	// section is the level of the tagged heading in section mode, or 0.
+	section int
+	lines   []string
+	end     int
+}
+
+// continues reports whether a line belongs to the block. blank is set when blank lines
+// precede the line.
+func (b *block) continues(line string, level int, blank bool) bool {
+	if b.section > 0 {
+		return level == 0 || level > b.section
+	}
+	if level > 0 || (blank && !b.list) {
+		return false
+	}
+	return indentWidth(line) > b.indent
+}
+
+// indentWidth returns the width of the leading whitespace of a line, counting tabs as four columns.
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// dedent removes the indentation shared by all lines.
+func dedent(lines []string) []string {
+	common := -1
+	for _, line := range lines {
+		if w := indentWidth(line); common < 0 || w < common {
+			common = w
+		}
+	}
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		width, j := 0, 0
+		for j < len(line) && width < common {
+			if line[j] == '\t' {
+				width += 4 - width%4
+			} else {
+				width++
+			}
+			j++
+		}
+		result[i] = line[j:]
+	}
+	return result
 }
 
-func extractTagsFromLine(line string) []string {
-	re := regexp.MustCompile(`(?:^|\s)(#[^\s#][^\s]*)`)
-	return re.FindAllString(line, -1)
+// structuredTag splits the k-th tag of a line into the tag name, the value of the line
+// without the tag and the attribute value. The attribute value of #key: extends to the
+// next tag; it is empty if the tag carries no value.
+func structuredTag(line string, tags []lineTag, k int) (string, string, string) {
+	t := tags[k]
+	m := attributeRegex.FindStringSubmatchIndex(line[t.start:])
+	if m == nil {
+		tag := strings.TrimSpace(t.text)
+		return tag, strings.TrimSpace(strings.Replace(line, t.text, "", -1)), ""
+	}
+
+	tag := line[t.start : t.start+m[1]]
+	name := "#" + line[t.start+m[2]:t.start+m[3]]
+	var attrValue string
+	switch {
+	case m[4] >= 0:
+		attrValue = line[t.start+m[4] : t.start+m[5]]
+	case m[6] >= 0:
+		attrValue = line[t.start+m[6] : t.start+m[7]]
+	default:
+		end := len(line)
+		if k+1 < len(tags) {
+			end = tags[k+1].start
+		}
+		attrValue = line[t.start+m[1] : end]
+	}
+
+	// Remove the tag together with the whitespace preceding it.
+	leading := len(t.text) - len(strings.TrimLeft(t.text, " \t"))
+	value := line[:t.start-leading] + line[t.start+len(tag):]
+	return name, strings.TrimSpace(value), strings.TrimSpace(attrValue)
 }
 
 /*
 ExtractTaggedLines extracts tagged lines from a file.
 
+The file is parsed as CommonMark with the GitHub Flavored Markdown extensions. Tags inside
+fenced or indented code, inline code, link destinations, autolinks, raw HTML and the front
+matter header are ignored. Every value records the 1-based line and column of its tag,
+the path of the headings enclosing it and the ID of the note.
+
+The lines following a tagged line continue its value when they are indented deeper than
+the tagged line, which includes nested list items. Blank lines end the value of a tagged
+paragraph, but not of a tagged list item. With config.App.SectionTags set, a tag on a
+heading covers the section up to the next heading of the same or a higher level. Tags
+without a value of their own are kept as values when they have continuation lines.
+
+With config.App.StructuredTags set, the tags #key: value, #key:value and #key(value)
+are indexed as #key, and every value of the line carries the typed attributes parsed
+from them.
+
 Usage:
 
 	fileName := "test.md"
@@ -81,73 +234,175 @@ Returns:
 	(internal.TagList): the tagged lines in the file contents with their values and file paths (if any)
 */
 func ExtractTaggedLines(fileName string, data []byte, config internal.Config) internal.TagList {
+	fm, _ := frontmatter.Parse(data)
+	return extractTaggedLines(fileName, data, fm, parseMarkdown(data, fm), config)
+}
+
+// extractTaggedLines implements ExtractTaggedLines for a file whose front matter and
+// markdown structure have been read.
+func extractTaggedLines(fileName string, data []byte, fm *frontmatter.FrontMatter, doc *markdownDoc, config internal.Config) internal.TagList {
 	var result internal.TagList
-	var currentTag string
-
-	reader := bytes.NewReader(data)
-	scanner := bufio.NewScanner(reader)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		tags := extractTagsFromLine(line)
-
-		if len(tags) > 0 {
-			for _, t := range tags {
-				tag := strings.TrimSpace(t)
-				value := strings.TrimSpace(strings.Replace(line, t, "", -1))
-				// value = strings.TrimSpace(RemoveHashtagsFromLine(value))
-
-				// Map the tag to its canonical type
-				canonicalType := MapTagToCanonicalType(tag, config)
-				if canonicalType == "" {
-					canonicalType = tag
-				}
-
-				if len(value) > 0 {
-					// This line has a value
-					found := false
-					for i := range result {
-						if result[i].Tag == canonicalType {
-							result[i].Tag = canonicalType
-							canonicalValue := internal.ResultValue{Line: value, FilePath: fileName}
-							result[i].Values = append(result[i].Values, canonicalValue)
-							found = true
-							break
-						}
-					}
-					if !found {
-						// This is the first line with this tag
-						currentValue := internal.ResultValue{Line: value, FilePath: fileName}
-						result = append(result, internal.TaggedLine{Tag: canonicalType, Values: []internal.ResultValue{currentValue}})
-
-					}
-				} else {
-					// This line is a tag with an empty value
-					found := false
-					for i := range result {
-						if result[i].Tag == canonicalType {
-							found = true
-							break
-						}
-					}
-					if !found {
-						// This is the first line with this tag
-						result = append(result, internal.TaggedLine{Tag: canonicalType, Values: []internal.ResultValue{}})
-					}
-				}
-
-				currentTag = canonicalType
-			}
+	// positions maps each tag to its index in result.
+	positions := make(map[string]int)
+	// open holds the tagged lines whose values may continue on the following lines.
+	var open []*block
+	blank := false
+
+	mapper := tagMapper(config)
+	id := noteID(fileName, fm, config)
+	lineNumber := 0
+
+	closeBlock := func(b *block) {
+		if len(b.lines) == 0 {
+			return
+		}
+		body := dedent(b.lines)
+		for _, ref := range b.refs {
+			value := &result[ref.tag].Values[ref.value]
+			value.Body = body
+			value.EndLine = b.end
+		}
+	}
+
+	for offset := 0; offset < len(data); {
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			end = len(data)
 		} else {
-			// This line is not a tag line, ignore it
-			if len(result) > 0 && currentTag == result[len(result)-1].Tag {
-				// This line is a continuation of the previous line
+			end += offset
+		}
+		line := strings.TrimSuffix(string(data[offset:end]), "\r")
+		tags := extractTagsFromLine(line, offset, doc.prose)
+		offset = end + 1
+		lineNumber++
+
+		if strings.TrimSpace(line) == "" {
+			blank = true
+			continue
+		}
+
+		level := doc.headingLevel(lineNumber)
+		kept := open[:0]
+		for _, b := range open {
+			if b.continues(line, level, blank) {
+				b.lines = append(b.lines, strings.TrimRight(line, " \t"))
+				b.end = lineNumber
+				kept = append(kept, b)
 			} else {
-				currentTag = ""
+				closeBlock(b)
+			}
+		}
+		open = kept
+		blank = false
+
+		if len(tags) == 0 {
+			continue
+		}
+
+		current := &block{indent: indentWidth(line), list: listItemRegex.MatchString(line)}
+		if level > 0 && config.App.SectionTags {
+			current.section = level
+		}
+		headingPath := doc.headingPath(lineNumber)
+		canonicalTags := make([]string, len(tags))
+		values := make([]string, len(tags))
+		var attributes []internal.Attribute
+		for k, t := range tags {
+			tag := strings.TrimSpace(t.text)
+			values[k] = strings.TrimSpace(strings.Replace(line, t.text, "", -1))
+			var attrValue string
+			if config.App.StructuredTags {
+				tag, values[k], attrValue = structuredTag(line, tags, k)
+			}
+
+			// Map the tag to its canonical type
+			canonicalTags[k], _ = mapper.CanonicalTag(tag)
+			if attrValue != "" {
+				attributes = append(attributes, ParseAttribute(strings.TrimPrefix(canonicalTags[k], "#"), attrValue))
 			}
 		}
+
+		for k, t := range tags {
+			canonicalType, value := canonicalTags[k], values[k]
+
+			i, found := positions[canonicalType]
+			if !found {
+				// This is the first line with this tag
+				i = len(result)
+				positions[canonicalType] = i
+				result = append(result, internal.TaggedLine{Tag: canonicalType, Values: []internal.ResultValue{}})
+			}
+			// Values that stay empty are removed once their continuation lines are known.
+			result[i].Values = append(result[i].Values, internal.ResultValue{
+				FilePath:   fileName,
+				Line:       value,
+				LineNumber: lineNumber,
+				Column:     utf8.RuneCountInString(line[:t.start]) + 1,
+				Heading:    headingPath,
+				NoteID:     id,
+				Attributes: attributes,
+				Origin:     internal.OriginHashtag,
+			})
+			current.refs = append(current.refs, valueRef{tag: i, value: len(result[i].Values) - 1})
+		}
+		// Headings only have continuation lines in section mode.
+		if level == 0 || current.section > 0 {
+			open = append(open, current)
+		}
+	}
+	for _, b := range open {
+		closeBlock(b)
+	}
+
+	for i := range result {
+		values := result[i].Values[:0]
+		for _, value := range result[i].Values {
+			if value.Line != "" || len(value.Body) > 0 {
+				values = append(values, value)
+			}
+		}
+		result[i].Values = values
+	}
+
+	// The tags of the front matter join those of the text, with the title as their line.
+	if tags := frontMatterTags(fm, mapper); len(tags) > 0 {
+		value := internal.ResultValue{
+			FilePath:   fileName,
+			Line:       fm.String("title"),
+			LineNumber: fm.Line(data, "tags"),
+			Column:     1,
+			NoteID:     id,
+			Origin:     internal.OriginFrontMatter,
+		}
+		for _, tag := range tags {
+			i, found := positions[tag]
+			if !found {
+				i = len(result)
+				positions[tag] = i
+				result = append(result, internal.TaggedLine{Tag: tag, Values: []internal.ResultValue{}})
+			}
+			result[i].Values = append(result[i].Values, value)
+		}
 	}
 
 	return result
 }
+
+// frontMatterTags returns the canonical form of the tags in the front matter, which may
+// be written with or without a leading '#'. Duplicates are dropped.
+func frontMatterTags(fm *frontmatter.FrontMatter, mapper internal.TagMapper) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range fm.List("tags") {
+		tag = "#" + strings.TrimPrefix(tag, "#")
+		if tag == "#" {
+			continue
+		}
+		canonical, _ := mapper.CanonicalTag(tag)
+		if !seen[canonical] {
+			seen[canonical] = true
+			tags = append(tags, canonical)
+		}
+	}
+	return tags
+}
@@ -18,9 +18,11 @@ func TestMapTagToCanonicalType(t *testing.T) {
 		{
 			tag: "tag1",
 			config: internal.Config{
-				TagMappings: map[string]string{
-					"tag1": "canonicalType1",
-					"tag2": "canonicalType2",
+				App: internal.AppConfig{
+					TagMappings: map[string]string{
+						"tag1": "canonicalType1",
+						"tag2": "canonicalType2",
+					},
 				},
 			},
 			expected: "canonicalType1",
@@ -28,9 +30,11 @@ func TestMapTagToCanonicalType(t *testing.T) {
 		{
 			tag: "tag2",
 			config: internal.Config{
-				TagMappings: map[string]string{
-					"tag1": "canonicalType1",
-					"tag2": "canonicalType2",
+				App: internal.AppConfig{
+					TagMappings: map[string]string{
+						"tag1": "canonicalType1",
+						"tag2": "canonicalType2",
+					},
 				},
 			},
 			expected: "canonicalType2",
@@ -38,9 +42,11 @@ func TestMapTagToCanonicalType(t *testing.T) {
 		{
 			tag: "tag3",
 			config: internal.Config{
-				TagMappings: map[string]string{
-					"tag1": "canonicalType1",
-					"tag2": "canonicalType2",
+				App: internal.AppConfig{
+					TagMappings: map[string]string{
+						"tag1": "canonicalType1",
+						"tag2": "canonicalType2",
+					},
 				},
 			},
 			expected: "",
@@ -90,21 +96,21 @@ func TestExtractTaggedLines(t *testing.T) {
 	// Define test data
 	fileName := "test.txt"
 	data := []byte("#tag1 value1\n#tag2 value2\nline 1\n#tag1 value3\n")
-	config := internal.Config{TagMappings: map[string]string{"#tag1": "#canonicalTag1", "#tag2": "#canonicalTag2"}}
+	config := internal.Config{App: internal.AppConfig{TagMappings: map[string]string{"#tag1": "#canonicalTag1", "#tag2": "#canonicalTag2"}}}
 
 	// Expected output
 	expected := internal.TagList{
 		{
 			Tag: "#canonicalTag1",
 			Values: []internal.ResultValue{
-				{FilePath: fileName, Line: "value1"},
-				{FilePath: fileName, Line: "value3"},
+				{FilePath: fileName, Line: "value1", LineNumber: 1, Column: 1, Origin: internal.OriginHashtag},
+				{FilePath: fileName, Line: "value3", LineNumber: 4, Column: 1, Origin: internal.OriginHashtag},
 			},
 		},
 		{
 			Tag: "#canonicalTag2",
 			Values: []internal.ResultValue{
-				{FilePath: fileName, Line: "value2"},
+				{FilePath: fileName, Line: "value2", LineNumber: 2, Column: 1, Origin: internal.OriginHashtag},
 			},
 		},
 	}
@@ -117,3 +123,185 @@ func TestExtractTaggedLines(t *testing.T) {
 		t.Errorf("ExtractTaggedLines() returned unexpected result:\nExpected: %v\nGot: %v", expected, result)
 	}
 }
+
+func TestExtractTaggedLinesIgnoresNonProse(t *testing.T) {
+	fileName := "test.md"
+	data := []byte(`---
+id: 1
+title: "#notatag"
+---
+# Heading with #real
+
+Use ` + "`#include`" + ` in C, see [the line](https://example.com/file#L42).
+Visit https://example.com/page#anchor or <https://example.com/#frag>.
+<span style="color: #fff">colored</span> #inline
+
+` + "```c" + `
+#include <stdio.h>
+` + "```" + `
+
+    #indented code
+
+<div>
+#html block
+</div>
+
+- item with #todo
+`)
+
+	result := utils.ExtractTaggedLines(fileName, data, internal.Config{})
+
+	var tags []string
+	for _, line := range result {
+		tags = append(tags, line.Tag)
+	}
+	expected := []string{"#real", "#inline", "#todo"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Expected tags %v, got %v", expected, tags)
+	}
+}
+
+func TestExtractTaggedLinesLocation(t *testing.T) {
+	fileName := "test.md"
+	data := []byte("---\nid: note-1\n---\n# Title #top\r\n\r\n## Sub `code`\r\n\r\nSome text, ünïcode #idea here\r\n\r\n### Deep\r\n\r\n## Other\r\n#todo done\r\n")
+
+	result := utils.ExtractTaggedLines(fileName, data, internal.Config{})
+
+	expected := internal.TagList{
+		{Tag: "#top", Values: []internal.ResultValue{
+			{FilePath: fileName, Line: "# Title", LineNumber: 4, Column: 9, Heading: "Title", NoteID: "note-1", Origin: internal.OriginHashtag},
+		}},
+		{Tag: "#idea", Values: []internal.ResultValue{
+			{FilePath: fileName, Line: "Some text, ünïcode here", LineNumber: 8, Column: 20, Heading: "Title > Sub code", NoteID: "note-1", Origin: internal.OriginHashtag},
+		}},
+		{Tag: "#todo", Values: []internal.ResultValue{
+			{FilePath: fileName, Line: "done", LineNumber: 13, Column: 1, Heading: "Title > Other", NoteID: "note-1", Origin: internal.OriginHashtag},
+		}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+}
+
+func TestExtractTaggedLinesContinuation(t *testing.T) {
+	fileName := "test.md"
+	data := []byte(`# Notes
+
+- #meeting weekly sync
+  - Alice: release is late
+
+    - #todo ask Bob
+- next item
+
+#idea paragraph
+    indented continuation
+
+    not a continuation after a blank line
+
+## Plan #plan
+
+Step one.
+
+### Details
+
+More.
+
+## Other
+`)
+
+	t.Run("lists and paragraphs", func(t *testing.T) {
+		result := utils.ExtractTaggedLines(fileName, data, internal.Config{})
+		expected := internal.TagList{
+			{Tag: "#meeting", Values: []internal.ResultValue{{
+				FilePath: fileName, Line: "- weekly sync", LineNumber: 3, Column: 3, Heading: "Notes", Origin: internal.OriginHashtag,
+				Body:    []string{"- Alice: release is late", "  - #todo ask Bob"},
+				EndLine: 6,
+			}}},
+			{Tag: "#todo", Values: []internal.ResultValue{
+				{FilePath: fileName, Line: "- ask Bob", LineNumber: 6, Column: 7, Heading: "Notes", Origin: internal.OriginHashtag},
+			}},
+			{Tag: "#idea", Values: []internal.ResultValue{{
+				FilePath: fileName, Line: "paragraph", LineNumber: 9, Column: 1, Heading: "Notes", Origin: internal.OriginHashtag,
+				Body:    []string{"indented continuation"},
+				EndLine: 10,
+			}}},
+			{Tag: "#plan", Values: []internal.ResultValue{
+				{FilePath: fileName, Line: "## Plan", LineNumber: 14, Column: 9, Heading: "Notes > Plan", Origin: internal.OriginHashtag},
+			}},
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, result)
+		}
+	})
+
+	t.Run("sections", func(t *testing.T) {
+		result := utils.ExtractTaggedLines(fileName, data, internal.Config{App: internal.AppConfig{SectionTags: true}})
+		var plan internal.TaggedLine
+		for _, line := range result {
+			if line.Tag == "#plan" {
+				plan = line
+			}
+		}
+		expected := []internal.ResultValue{{
+			FilePath: fileName, Line: "## Plan", LineNumber: 14, Column: 9, Heading: "Notes > Plan", Origin: internal.OriginHashtag,
+			Body:    []string{"Step one.", "### Details", "More."},
+			EndLine: 20,
+		}}
+		if !reflect.DeepEqual(plan.Values, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, plan.Values)
+		}
+	})
+}
+
+func TestExtractTaggedLinesKeepsBareTagWithBody(t *testing.T) {
+	data := []byte("#meeting\n  agenda\n\n#empty\n")
+	result := utils.ExtractTaggedLines("test.md", data, internal.Config{})
+
+	expected := internal.TagList{
+		{Tag: "#meeting", Values: []internal.ResultValue{
+			{FilePath: "test.md", Body: []string{"agenda"}, EndLine: 2, LineNumber: 1, Column: 1, Origin: internal.OriginHashtag},
+		}},
+		{Tag: "#empty", Values: []internal.ResultValue{}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+}
+
+func TestExtractTaggedLinesStructured(t *testing.T) {
+	data := []byte("Report #due: 2026-11-01 #priority(very high) #weight:72\n#todo: buy milk\n")
+	config := internal.Config{App: internal.AppConfig{StructuredTags: true}}
+	result := utils.ExtractTaggedLines("test.md", data, config)
+
+	var tags []string
+	for _, line := range result {
+		tags = append(tags, line.Tag)
+	}
+	if expected := []string{"#due", "#priority", "#weight", "#todo"}; !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("Expected tags %v, got %v", expected, tags)
+	}
+
+	first := result[0].Values[0]
+	if first.Line != "Report 2026-11-01 #priority(very high) #weight:72" {
+		t.Errorf("Unexpected value %q", first.Line)
+	}
+	var attrs []string
+	for _, attr := range first.Attributes {
+		attrs = append(attrs, attr.Key+"="+attr.Value+":"+attr.Type)
+	}
+	expected := []string{"due=2026-11-01:date", "priority=very high:string", "weight=72:number"}
+	if !reflect.DeepEqual(attrs, expected) {
+		t.Errorf("Expected attributes %v, got %v", expected, attrs)
+	}
+	if !reflect.DeepEqual(result[1].Values[0].Attributes, first.Attributes) {
+		t.Errorf("Expected every value of the line to carry its attributes")
+	}
+	if got := result[1].Values[0].Line; got != "Report #due: 2026-11-01 #weight:72" {
+		t.Errorf("Unexpected value %q", got)
+	}
+
+	todo := result[3].Values[0]
+	if todo.Line != "buy milk" || len(todo.Attributes) != 1 || todo.Attributes[0].Value != "buy milk" {
+		t.Errorf("Unexpected value %+v", todo)
+	}
+}
@@ -1,113 +1,74 @@
 package utils
 
 import (
-	"fmt"
 	"io/ioutil"
-	"regexp"
-	"strings"
 
-	"github.com/google/uuid"
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/frontmatter"
+	"github.com/ozcankasal/zettelo/internal/ids"
 )
 
-func SyncHeader(filePath string) {
-	header := readHeader(filePath)
-	if header != "" {
-		id := getId(header)
+/*
+AssignID returns the content with the given ID added to its header. A YAML header is created
+when the content has none; YAML, TOML and JSON headers are edited in place, keeping their
+comments and the order of their keys. Content that already has an ID is returned unchanged.
 
-		if id != "" {
+Usage:
 
-		} else {
-			fmt.Println("No ID found in header of", filePath)
-			newHeaderText := addId(header, getUUID())
-			updateHeader(filePath, newHeaderText)
-		}
-	} else {
-		fmt.Println("No header found in", filePath)
-		addHeader(filePath)
-		newHeaderText := addId(readHeader(filePath), getUUID())
-		updateHeader(filePath, newHeaderText)
-	}
-}
+	newContent, changed, err := AssignID(content, "e9eb51f7-0706-4eb8-a343-6c0c7f4f6e4d")
 
-func checkHeader(filePath string) bool {
-	content, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		fmt.Println(err)
-	}
+Parameters:
 
-	headerRegex := regexp.MustCompile(`(?s)\A---\n(.+)\n---\n`)
-	headerMatches := headerRegex.FindStringSubmatch(string(content))
+	content ([]byte): the markdown file contents
+	id (string): the ID to add
 
-	if len(headerMatches) > 1 {
-		return true
-	} else {
-		return false
-	}
-}
+Returns:
 
-func readHeader(filePath string) string {
-	content, err := ioutil.ReadFile(filePath)
+	([]byte): the updated contents
+	(bool): true if the contents were changed
+	(error): if the header could not be decoded, in which case it is left alone
+*/
+func AssignID(content []byte, id string) ([]byte, bool, error) {
+	fm, err := frontmatter.Parse(content)
 	if err != nil {
-		fmt.Println(err)
+		return content, false, err
 	}
-
-	headerRegex := regexp.MustCompile(`(?s)\A---\n(.+)\n---\n`)
-	headerMatches := headerRegex.FindStringSubmatch(string(content))
-	if len(headerMatches) > 1 {
-		return headerMatches[1]
-	} else {
-		return ""
+	if fm.String("id") != "" {
+		return content, false, nil
 	}
-}
 
-func getId(headerText string) string {
-	idRegex := regexp.MustCompile(`(\w+):\s*(.*)\n`)
-	idMatches := idRegex.FindStringSubmatch(headerText)
-	if len(idMatches) > 2 {
-		return idMatches[2]
-	} else {
-		return ""
+	newContent, err := frontmatter.Set(content, "id", id)
+	if err != nil {
+		return content, false, err
 	}
+	return newContent, true, nil
 }
 
-// this function adds an ID to the header text already given in an extra line
-func addId(headerText string, uuid string) string {
-	newText := headerText + "\n" + "id: " + uuid + "\n"
-	return newText
-}
-
-// this function generates new uuid
-func getUUID() string {
-	uuid := uuid.New()
-	return uuid.String()
-}
-
-// this function writes the new header text to the file
-func updateHeader(filePath string, newHeaderText string) {
-	content, err := ioutil.ReadFile(filePath)
+// IDScheme returns the ID scheme of the configuration. ParseConfig has checked its name;
+// an unknown name selects uuid4.
+func IDScheme(config internal.Config) ids.Scheme {
+	scheme, err := ids.New(config.App.IDScheme)
 	if err != nil {
-		fmt.Println(err)
+		scheme, _ = ids.New(ids.UUID4)
 	}
+	return scheme
+}
 
-	headerRegex := regexp.MustCompile(`(?s)\A---\n(.+)\n---\n`)
-	headerMatches := headerRegex.FindStringSubmatch(string(content))
-	if len(headerMatches) > 1 {
-		headerText := headerMatches[1]
-		newContent := strings.Replace(string(content), headerText, newHeaderText, 1)
-
-		// write the new content to the file
-		ioutil.WriteFile(filePath, []byte(newContent), 0)
+// noteID returns the id from the front matter of a note or, in filename prefix mode, the
+// ID its file name starts with.
+func noteID(path string, fm *frontmatter.FrontMatter, config internal.Config) string {
+	if id := fm.String("id"); id != "" || !config.App.IDFilenamePrefix {
+		return id
 	}
+	return ids.FilenamePrefix(IDScheme(config), path)
 }
 
-func addHeader(filePath string) {
+// ReadID returns the ID of the note in the file, see ParseNote, or an empty string.
+func ReadID(filePath string, config internal.Config) string {
 	content, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		fmt.Println(err)
+		return ""
 	}
-	headerText := "---\n \n---\n"
-	newContent := headerText + string(content)
-
-	// write the new content to the file
-	ioutil.WriteFile(filePath, []byte(newContent), 0)
+	fm, _ := frontmatter.Parse(content)
+	return noteID(filePath, fm, config)
 }
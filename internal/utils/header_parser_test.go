@@ -0,0 +1,104 @@
+package utils_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func TestAssignID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+		changed  bool
+	}{
+		{
+			name:     "no header",
+			content:  "# Title\n",
+			expected: "---\nid: 42\n---\n# Title\n",
+			changed:  true,
+		},
+		{
+			name:     "header without id",
+			content:  "---\ntype: concept\n---\n# Title\n",
+			expected: "---\ntype: concept\nid: 42\n---\n# Title\n",
+			changed:  true,
+		},
+		{
+			name:     "header with another key first",
+			content:  "---\ntitle: A note\n---\n# Title\n",
+			expected: "---\ntitle: A note\nid: 42\n---\n# Title\n",
+			changed:  true,
+		},
+		{
+			name:     "crlf header",
+			content:  "---\r\ntype: concept\r\n---\r\n# Title\r\n",
+			expected: "---\r\ntype: concept\r\nid: 42\r\n---\r\n# Title\r\n",
+			changed:  true,
+		},
+		{
+			name:     "toml header",
+			content:  "+++\ntitle = \"A\"\n+++\n# Title\n",
+			expected: "+++\ntitle = \"A\"\nid = \"42\"\n+++\n# Title\n",
+			changed:  true,
+		},
+		{
+			name:     "header with id",
+			content:  "---\nid: 7\n---\n# Title\n",
+			expected: "---\nid: 7\n---\n# Title\n",
+			changed:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, changed, err := utils.AssignID([]byte(tc.content), "42")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(actual) != tc.expected || changed != tc.changed {
+				t.Errorf("Expected %q (%v), got %q (%v)", tc.expected, tc.changed, actual, changed)
+			}
+		})
+	}
+}
+
+func TestAssignIDLeavesInvalidHeaderAlone(t *testing.T) {
+	content := []byte("---\ntags: [a\n---\n# Title\n")
+	actual, changed, err := utils.AssignID(content, "42")
+	if err == nil || changed || string(actual) != string(content) {
+		t.Errorf("Expected an error and no change, got %q (%v, %v)", actual, changed, err)
+	}
+}
+
+func TestReadIDFromFilenamePrefix(t *testing.T) {
+	dir := t.TempDir()
+	config := internal.Config{App: internal.AppConfig{IDScheme: "timestamp", IDFilenamePrefix: true}}
+
+	prefixed := filepath.Join(dir, "202610181230 Limits.md")
+	withHeader := filepath.Join(dir, "202610181231 Series.md")
+	plain := filepath.Join(dir, "Notes.md")
+	for path, content := range map[string]string{prefixed: "# Limits\n", withHeader: "---\nid: 7\n---\n", plain: "# Notes\n"} {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if id := utils.ReadID(prefixed, config); id != "202610181230" {
+		t.Errorf("Expected the ID of the file name, got %q", id)
+	}
+	if id := utils.ReadID(withHeader, config); id != "7" {
+		t.Errorf("Expected the ID of the front matter to win, got %q", id)
+	}
+	if id := utils.ReadID(plain, config); id != "" {
+		t.Errorf("Expected no ID, got %q", id)
+	}
+	config.App.IDFilenamePrefix = false
+	if id := utils.ReadID(prefixed, config); id != "" {
+		t.Errorf("Expected the file name to be ignored without prefix mode, got %q", id)
+	}
+}
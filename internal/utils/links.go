@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ozcankasal/zettelo/internal"
+)
+
+// wikiLinkRegex matches [[target]], [[target#heading]] and [[target|alias]].
+var wikiLinkRegex = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+// extractLinks returns the wiki links in the prose of a note and its markdown links with
+// a relative destination, in document order.
+func extractLinks(data []byte, doc *markdownDoc) []internal.Link {
+	type found struct {
+		link   internal.Link
+		offset int
+	}
+	var all []found
+	for _, m := range wikiLinkRegex.FindAllSubmatchIndex(data, -1) {
+		if !doc.prose[m[0]] {
+			continue
+		}
+		all = append(all, found{parseWikiLink(string(data[m[2]:m[3]])), m[0]})
+	}
+	for _, l := range doc.links {
+		if link, ok := parseMarkdownLink(l.destination); ok {
+			all = append(all, found{link, l.offset})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].offset < all[j].offset })
+
+	links := make([]internal.Link, len(all))
+	for i, f := range all {
+		links[i] = f.link
+		links[i].LineNumber = doc.lineAt(f.offset)
+		lineStart := doc.lineStarts[links[i].LineNumber-1]
+		links[i].Column = utf8.RuneCount(data[lineStart:f.offset]) + 1
+	}
+	return links
+}
+
+// parseWikiLink splits the text between the brackets of a wiki link into its target,
+// heading and alias.
+func parseWikiLink(inner string) internal.Link {
+	target, alias, _ := strings.Cut(inner, "|")
+	target, heading, _ := strings.Cut(target, "#")
+	return internal.Link{
+		Kind:    internal.LinkWiki,
+		Target:  strings.TrimSpace(target),
+		Heading: strings.TrimSpace(heading),
+		Alias:   strings.TrimSpace(alias),
+	}
+}
+
+// parseMarkdownLink returns the link for the destination of a markdown link. Only
+// relative paths of markdown files, with or without their extension, link to notes.
+func parseMarkdownLink(destination string) (internal.Link, bool) {
+	u, err := url.Parse(destination)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || path.IsAbs(u.Path) {
+		return internal.Link{}, false
+	}
+	if ext := strings.ToLower(path.Ext(u.Path)); ext != "" && ext != ".md" && ext != ".markdown" {
+		return internal.Link{}, false
+	}
+	return internal.Link{Kind: internal.LinkMarkdown, Target: u.Path, Heading: u.Fragment}, true
+}
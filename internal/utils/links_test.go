@@ -0,0 +1,46 @@
+package utils_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func TestParseNoteLinks(t *testing.T) {
+	data := []byte(`---
+aliases: [Limit, lim]
+---
+# Limits
+
+See [[Continuity]] and [[202610181230|the proof]].
+Also [[Series#Convergence|convergent series]], [[#Definition]] and [the rules](../rules/L'Hôpital.md#usage).
+Ignore [a site](https://example.com), [an image](plot.png), ` + "`[[code]]`" + ` and [anchors](#top).
+
+` + "```" + `
+[[Fenced]]
+` + "```" + `
+- ünïcode [[Über]]
+`)
+
+	note := utils.ParseNote("notes/limits.md", data, internal.Config{})
+	if note.Title != "Limits" {
+		t.Errorf("Expected the heading as title, got %q", note.Title)
+	}
+	if !reflect.DeepEqual(note.Aliases, []string{"Limit", "lim"}) {
+		t.Errorf("Unexpected aliases %v", note.Aliases)
+	}
+
+	expected := []internal.Link{
+		{Kind: internal.LinkWiki, Target: "Continuity", LineNumber: 6, Column: 5},
+		{Kind: internal.LinkWiki, Target: "202610181230", Alias: "the proof", LineNumber: 6, Column: 24},
+		{Kind: internal.LinkWiki, Target: "Series", Heading: "Convergence", Alias: "convergent series", LineNumber: 7, Column: 6},
+		{Kind: internal.LinkWiki, Heading: "Definition", LineNumber: 7, Column: 48},
+		{Kind: internal.LinkMarkdown, Target: "../rules/L'Hôpital.md", Heading: "usage", LineNumber: 7, Column: 68},
+		{Kind: internal.LinkWiki, Target: "Über", LineNumber: 13, Column: 11},
+	}
+	if !reflect.DeepEqual(note.Links, expected) {
+		t.Errorf("Expected links\n%+v\ngot\n%+v", expected, note.Links)
+	}
+}
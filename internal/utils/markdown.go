@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+
+	"github.com/ozcankasal/zettelo/internal/frontmatter"
+)
+
+// markdownParser parses CommonMark with the GitHub Flavored Markdown extensions.
+var markdownParser = goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser()
+
+// markdownDoc is the structure of a markdown document needed to extract tags and links.
+type markdownDoc struct {
+	// prose marks the bytes that belong to prose, where tags may appear. Code blocks,
+	// inline code, link destinations, autolinks, raw HTML and the front matter header
+	// are not prose.
+	prose []bool
+	// lineStarts holds the byte offset of every line.
+	lineStarts []int
+	// headings lists the headings in document order.
+	headings []heading
+	// links lists the markdown links in document order.
+	links []markdownLink
+}
+
+type markdownLink struct {
+	destination string
+	offset      int // of the opening bracket
+}
+
+type heading struct {
+	line  int // 1-based
+	level int
+	title string
+}
+
+/*
+parseMarkdown parses a markdown document.
+
+Usage:
+
+	doc := parseMarkdown(data, fm)
+	if doc.prose[i] {
+		// data[i] is part of the text of the document
+	}
+
+Parameters:
+
+	data ([]byte): the markdown document
+	fm (*frontmatter.FrontMatter): the front matter of the document, or nil
+
+Returns:
+
+	(*markdownDoc): the prose mask, line offsets, headings and links of the document
+*/
+func parseMarkdown(data []byte, fm *frontmatter.FrontMatter) *markdownDoc {
+	source := data
+	if fm != nil {
+		// Blank the header so it is not parsed as a thematic break and a heading,
+		// keeping the offsets of everything that follows.
+		source = make([]byte, len(data))
+		copy(source, data)
+		for i := 0; i < fm.End; i++ {
+			if source[i] != '\n' {
+				source[i] = ' '
+			}
+		}
+	}
+
+	doc := &markdownDoc{prose: make([]bool, len(source)), lineStarts: []int{0}}
+	for i, b := range source {
+		if b == '\n' {
+			doc.lineStarts = append(doc.lineStarts, i+1)
+		}
+	}
+
+	root := markdownParser.Parse(text.NewReader(source))
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *ast.CodeSpan, *ast.RawHTML, *ast.AutoLink:
+			return ast.WalkSkipChildren, nil
+		case *ast.Heading:
+			if node.Lines().Len() > 0 {
+				doc.headings = append(doc.headings, heading{
+					line:  doc.lineAt(node.Lines().At(0).Start),
+					level: node.Level,
+					title: headingTitle(node, source),
+				})
+			}
+		case *ast.Link:
+			if start := textStart(node); start > 0 {
+				offset := bytes.LastIndexByte(source[:start], '[')
+				doc.links = append(doc.links, markdownLink{destination: string(node.Destination), offset: offset})
+			}
+		case *ast.Text:
+			for i := node.Segment.Start; i < node.Segment.Stop; i++ {
+				doc.prose[i] = true
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return doc
+}
+
+// textStart returns the offset of the first text in a node, or -1 if it has none.
+func textStart(n ast.Node) int {
+	start := -1
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := c.(*ast.Text); ok && entering {
+			start = t.Segment.Start
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return start
+}
+
+// title returns the text of the first level 1 heading, or an empty string.
+func (d *markdownDoc) title() string {
+	for _, h := range d.headings {
+		if h.level == 1 {
+			return h.title
+		}
+	}
+	return ""
+}
+
+// headingLevel returns the level of the heading on a line, or 0 if the line is not a heading.
+func (d *markdownDoc) headingLevel(line int) int {
+	i := sort.Search(len(d.headings), func(i int) bool { return d.headings[i].line >= line })
+	if i < len(d.headings) && d.headings[i].line == line {
+		return d.headings[i].level
+	}
+	return 0
+}
+
+// lineAt returns the 1-based line number of a byte offset.
+func (d *markdownDoc) lineAt(offset int) int {
+	return sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset })
+}
+
+// headingPath returns the titles of the headings enclosing a line, outermost first and
+// separated by " > ". A heading line belongs to its own section.
+func (d *markdownDoc) headingPath(line int) string {
+	var stack []heading
+	for _, h := range d.headings {
+		if h.line > line {
+			break
+		}
+		for len(stack) > 0 && stack[len(stack)-1].level >= h.level {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, h)
+	}
+
+	titles := make([]string, len(stack))
+	for i, h := range stack {
+		titles[i] = h.title
+	}
+	return strings.Join(titles, " > ")
+}
+
+// headingTitle returns the plain text of a heading without its tags.
+func headingTitle(n ast.Node, source []byte) string {
+	var sb strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := c.(type) {
+		case *ast.Text:
+			sb.Write(node.Segment.Value(source))
+		case *ast.CodeSpan:
+			for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+				if t, ok := child.(*ast.Text); ok {
+					sb.Write(t.Segment.Value(source))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(RemoveHashtagsFromLine(sb.String()))
+}
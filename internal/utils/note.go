@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/frontmatter"
+)
+
+/*
+ParseNote reads the front matter fields, the tagged lines and the links of a note. The
+title is taken from the front matter or else from the first level 1 heading. A front
+matter that cannot be decoded is reported in FrontMatterError; the tagged lines and links
+are extracted regardless.
+
+Usage:
+
+	note := ParseNote("notes/a.md", data, config)
+
+Parameters:
+
+	path (string): the path of the note
+	data ([]byte): the contents of the note
+	config (internal.Config): the configuration to use
+
+Returns:
+
+	(internal.Note): the note
+*/
+func ParseNote(path string, data []byte, config internal.Config) internal.Note {
+	// Compile the tag rules once for the tags of the front matter and of the text.
+	config.App.Mapper = tagMapper(config)
+	fm, err := frontmatter.Parse(data)
+	doc := parseMarkdown(data, fm)
+	note := internal.Note{
+		Path:    path,
+		ID:      noteID(path, fm, config),
+		Title:   fm.String("title"),
+		Type:    fm.String("type"),
+		Project: fm.String("project"),
+		Tags:    frontMatterTags(fm, config.App.Mapper),
+		Aliases: fm.List("aliases"),
+		Links:   extractLinks(data, doc),
+		Lines:   extractTaggedLines(path, data, fm, doc, config),
+	}
+	if note.Title == "" {
+		note.Title = doc.title()
+	}
+	if err != nil {
+		note.FrontMatterError = err.Error()
+	}
+	return note
+}
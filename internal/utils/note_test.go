@@ -0,0 +1,80 @@
+package utils_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func TestParseNote(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		tagsLine int
+	}{
+		{
+			name:     "yaml",
+			content:  "---\nid: 42\ntitle: Limits\ntype: concept\nproject: maths-book-writing\ntags: [calculus, analysis]\n---\nSee #idea here\n",
+			tagsLine: 6,
+		},
+		{
+			name:     "toml",
+			content:  "+++\nid = 42\ntitle = \"Limits\"\ntype = \"concept\"\nproject = \"maths-book-writing\"\ntags = [\"calculus\", \"analysis\"]\n+++\nSee #idea here\n",
+			tagsLine: 6,
+		},
+		{
+			name:     "json",
+			content:  "{\"id\": \"42\", \"title\": \"Limits\", \"type\": \"concept\", \"project\": \"maths-book-writing\", \"tags\": \"calculus, #analysis, calculus\"}\nSee #idea here\n",
+			tagsLine: 1,
+		},
+		{
+			name:     "bom and crlf",
+			content:  "\ufeff---\r\nid: 42\r\ntitle: Limits\r\ntype: concept\r\nproject: maths-book-writing\r\ntags:\r\n  - calculus\r\n  - analysis\r\n---\r\nSee #idea here\r\n",
+			tagsLine: 6,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			note := utils.ParseNote("a.md", []byte(tc.content), internal.Config{})
+			if note.FrontMatterError != "" {
+				t.Fatalf("Unexpected front matter error: %s", note.FrontMatterError)
+			}
+			if note.Path != "a.md" || note.ID != "42" || note.Title != "Limits" || note.Type != "concept" || note.Project != "maths-book-writing" {
+				t.Errorf("Unexpected note fields: %+v", note)
+			}
+			if !reflect.DeepEqual(note.Tags, []string{"#calculus", "#analysis"}) {
+				t.Errorf("Expected tags [#calculus #analysis], got %v", note.Tags)
+			}
+
+			origins := make(map[string]string)
+			for _, line := range note.Lines {
+				for _, value := range line.Values {
+					origins[line.Tag] = value.Origin
+					if value.NoteID != "42" {
+						t.Errorf("Expected note ID 42 for %s, got %q", line.Tag, value.NoteID)
+					}
+					if value.Origin == internal.OriginFrontMatter && (value.Line != "Limits" || value.LineNumber != tc.tagsLine) {
+						t.Errorf("Expected the title on line %d for %s, got %+v", tc.tagsLine, line.Tag, value)
+					}
+				}
+			}
+			expected := map[string]string{"#idea": internal.OriginHashtag, "#calculus": internal.OriginFrontMatter, "#analysis": internal.OriginFrontMatter}
+			if !reflect.DeepEqual(origins, expected) {
+				t.Errorf("Expected origins %v, got %v", expected, origins)
+			}
+		})
+	}
+}
+
+func TestParseNoteInvalidFrontMatter(t *testing.T) {
+	note := utils.ParseNote("a.md", []byte("---\ntags: [a\n---\nSee #idea here\n"), internal.Config{})
+	if note.FrontMatterError == "" {
+		t.Errorf("Expected a front matter error")
+	}
+	if len(note.Lines) != 1 || note.Lines[0].Tag != "#idea" {
+		t.Errorf("Expected the tagged lines to be indexed, got %+v", note.Lines)
+	}
+}
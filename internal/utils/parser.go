@@ -9,10 +9,13 @@ package utils
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"io"
 	"sort"
+	"time"
 
 	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/ids"
 	"gopkg.in/yaml.v2"
 )
 
@@ -82,12 +85,44 @@ func ReadJSON(r io.Reader) (internal.TagList, error) {
 	return lines, err
 }
 
-func ParseConfig(configData []byte) (*internal.Config, error) {
+// DefaultDebounce is the debounce window used when the configuration does not set one.
+const DefaultDebounce = 100 * time.Millisecond
+
+// DefaultEditorURL opens the location of a tag in Visual Studio Code.
+const DefaultEditorURL = "vscode://file{path}:{line}:{column}"
+
+/*
+ParseConfig parses a YAML configuration. Settings missing from the YAML keep their defaults.
+The tag rules are compiled and checked, see NewTagMapper.
+
+Usage:
+
+	config, err := ParseConfig(configData)
+
+Parameters:
 
+	configData ([]byte): the YAML configuration
+
+Returns:
+
+	(*internal.Config): the parsed configuration
+	(error): if the YAML could not be parsed or has invalid tag rules
+*/
+func ParseConfig(configData []byte) (*internal.Config, error) {
 	var config internal.Config
+	config.App.Debounce = DefaultDebounce
+	config.Web.EditorURL = DefaultEditorURL
 	err := yaml.Unmarshal(configData, &config)
 	if err != nil {
 		return nil, err
 	}
+	mapper, err := NewTagMapper(config.App)
+	if err != nil {
+		return nil, err
+	}
+	config.App.Mapper = mapper
+	if _, err := ids.New(config.App.IDScheme); err != nil {
+		return nil, fmt.Errorf("app.id_scheme: %w", err)
+	}
 	return &config, nil
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ozcankasal/zettelo/internal"
 	"github.com/ozcankasal/zettelo/internal/utils"
@@ -128,3 +129,25 @@ func TestReadJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestParseConfig(t *testing.T) {
+	config, err := utils.ParseConfig([]byte("app:\n  folders:\n    - /notes\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if config.App.Debounce != utils.DefaultDebounce {
+		t.Errorf("Expected default debounce %v, got %v", utils.DefaultDebounce, config.App.Debounce)
+	}
+
+	config, err = utils.ParseConfig([]byte("app:\n  debounce: 250ms\n  assign_ids: true\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if config.App.Debounce != 250*time.Millisecond || !config.App.AssignIDs {
+		t.Errorf("Unexpected configuration: %+v", config.App)
+	}
+
+	if _, err := utils.ParseConfig([]byte("app:\n  id_scheme: sequential\n")); err == nil {
+		t.Errorf("Expected an error for an unknown id_scheme")
+	}
+}
@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/ozcankasal/zettelo/internal"
+)
+
+// Kinds of tag rules.
+const (
+	RuleExact = "exact"
+	RuleGlob  = "glob"
+	RuleRegex = "regex"
+)
+
+// Prefixes selecting the kind of a rule pattern.
+const (
+	globPrefix  = "glob:"
+	regexPrefix = "re:"
+)
+
+// TagRule is one mapping from a tag pattern to a canonical tag.
+type TagRule struct {
+	// Source is the setting the rule comes from: tag_mappings or tag_synonyms.
+	Source string
+	// Pattern is the key or list entry as written in the configuration.
+	Pattern   string
+	Kind      string
+	Canonical string
+
+	match string
+	re    *regexp.Regexp
+}
+
+func (r *TagRule) String() string {
+	return fmt.Sprintf("%s %q (%s)", r.Source, r.Pattern, r.Kind)
+}
+
+// TagMapper maps tags to their canonical form using the rules of a configuration.
+// It is safe for concurrent use.
+type TagMapper struct {
+	exact    map[string]*TagRule
+	patterns []*TagRule
+	fold     bool
+	form     *norm.Form
+}
+
+/*
+NewTagMapper compiles the tag rules of a configuration.
+
+Keys of tag_mappings and entries of tag_synonyms are exact tags, or patterns when they
+start with "glob:" (where * and ? do not match '/') or "re:" (a regular expression that
+must match the whole tag). Exact rules are tried first, then the patterns of tag_mappings
+in the order of their keys, then those of tag_synonyms in the order of the canonical
+tags and their lists. Tags and exact keys are normalised to the Unicode form of
+unicode_normalization (NFC unless set to NFKC or none) and, with fold_case, case folded.
+
+Usage:
+
+	mapper, err := NewTagMapper(config.App)
+
+Parameters:
+
+	app (internal.AppConfig): the application settings holding the rules
+
+Returns:
+
+	(*TagMapper): the mapper; invalid rules are left out of it
+	(error): if rules are invalid or an exact tag maps to two canonical tags
+*/
+func NewTagMapper(app internal.AppConfig) (*TagMapper, error) {
+	m := &TagMapper{exact: make(map[string]*TagRule), fold: app.FoldCase}
+	switch strings.ToUpper(app.UnicodeNormalization) {
+	case "", "NFC":
+		form := norm.NFC
+		m.form = &form
+	case "NFKC":
+		form := norm.NFKC
+		m.form = &form
+	case "NONE":
+	default:
+		return m, fmt.Errorf("unknown unicode_normalization %q", app.UnicodeNormalization)
+	}
+
+	var errs []error
+	add := func(rule *TagRule) {
+		if err := m.add(rule); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	keys := make([]string, 0, len(app.TagMappings))
+	for key := range app.TagMappings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add(&TagRule{Source: "tag_mappings", Pattern: key, Canonical: app.TagMappings[key]})
+	}
+
+	canonicals := make([]string, 0, len(app.TagSynonyms))
+	for canonical := range app.TagSynonyms {
+		canonicals = append(canonicals, canonical)
+	}
+	sort.Strings(canonicals)
+	for _, canonical := range canonicals {
+		for _, synonym := range app.TagSynonyms[canonical] {
+			add(&TagRule{Source: "tag_synonyms", Pattern: synonym, Canonical: canonical})
+		}
+	}
+
+	return m, errors.Join(errs...)
+}
+
+// add compiles a rule and adds it to the mapper.
+func (m *TagMapper) add(rule *TagRule) error {
+	rule.Canonical = m.normalize(rule.Canonical)
+	switch {
+	case strings.HasPrefix(rule.Pattern, regexPrefix):
+		rule.Kind = RuleRegex
+		expr := "^(?:" + m.normalize(strings.TrimPrefix(rule.Pattern, regexPrefix)) + ")$"
+		if m.fold {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("%s %q: %w", rule.Source, rule.Pattern, err)
+		}
+		rule.re = re
+	case strings.HasPrefix(rule.Pattern, globPrefix):
+		rule.Kind = RuleGlob
+		rule.match = m.key(strings.TrimPrefix(rule.Pattern, globPrefix))
+		if _, err := path.Match(rule.match, ""); err != nil {
+			return fmt.Errorf("%s %q: %w", rule.Source, rule.Pattern, err)
+		}
+	default:
+		rule.Kind = RuleExact
+		rule.match = m.key(rule.Pattern)
+		if other, ok := m.exact[rule.match]; ok {
+			if other.Canonical != rule.Canonical {
+				return fmt.Errorf("%s %q: %q is already mapped to %q by %s %q", rule.Source, rule.Pattern, rule.match, other.Canonical, other.Source, other.Pattern)
+			}
+			return nil
+		}
+		m.exact[rule.match] = rule
+		return nil
+	}
+	m.patterns = append(m.patterns, rule)
+	return nil
+}
+
+// normalize returns the tag in the configured Unicode normal form.
+func (m *TagMapper) normalize(tag string) string {
+	if m.form == nil {
+		return tag
+	}
+	return m.form.String(tag)
+}
+
+// key returns the form of a tag used for matching: normalised and, with fold_case,
+// case folded.
+func (m *TagMapper) key(tag string) string {
+	tag = m.normalize(tag)
+	if m.fold {
+		// A Caser is not safe for concurrent use, so every call makes its own.
+		tag = cases.Fold().String(tag)
+	}
+	return tag
+}
+
+/*
+Explain returns the canonical form of a tag and the rule that produced it. Tags matched
+by no rule are returned normalised, and case folded with fold_case, with a nil rule.
+
+Usage:
+
+	canonical, rule := mapper.Explain("#To-Do")
+
+Parameters:
+
+	tag (string): the tag to map
+
+Returns:
+
+	(string): the canonical tag
+	(*TagRule): the rule that matched, or nil
+*/
+func (m *TagMapper) Explain(tag string) (string, *TagRule) {
+	key := m.key(tag)
+	if rule, ok := m.exact[key]; ok {
+		return rule.Canonical, rule
+	}
+	for _, rule := range m.patterns {
+		if rule.re != nil {
+			if rule.re.MatchString(key) {
+				return rule.Canonical, rule
+			}
+		} else if ok, _ := path.Match(rule.match, key); ok {
+			return rule.Canonical, rule
+		}
+	}
+	return key, nil
+}
+
+// CanonicalTag implements internal.TagMapper.
+func (m *TagMapper) CanonicalTag(tag string) (string, bool) {
+	canonical, rule := m.Explain(tag)
+	return canonical, rule != nil
+}
+
+// tagMapper returns the compiled mapper of a configuration, compiling it when the
+// configuration was not read by ParseConfig.
+func tagMapper(config internal.Config) internal.TagMapper {
+	if config.App.Mapper != nil {
+		return config.App.Mapper
+	}
+	mapper, _ := NewTagMapper(config.App)
+	return mapper
+}
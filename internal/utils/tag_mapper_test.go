@@ -0,0 +1,131 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ozcankasal/zettelo/internal"
+	"github.com/ozcankasal/zettelo/internal/utils"
+)
+
+func TestTagMapper(t *testing.T) {
+	app := internal.AppConfig{
+		TagMappings: map[string]string{
+			"#to-do":           "#todo",
+			"re:#to-?do:?":     "#todo",
+			"glob:#proj-*":     "#project",
+			"re:#(?:Q|q)[1-4]": "#quarter",
+		},
+		TagSynonyms: map[string][]string{
+			"#idea": {"#insight", "#thought", "glob:#idea?"},
+		},
+		FoldCase: true,
+	}
+	mapper, err := utils.NewTagMapper(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		tag       string
+		canonical string
+		pattern   string
+	}{
+		{tag: "#To-Do", canonical: "#todo", pattern: "#to-do"},
+		{tag: "#TODO:", canonical: "#todo", pattern: "re:#to-?do:?"},
+		{tag: "#todo", canonical: "#todo", pattern: "re:#to-?do:?"},
+		{tag: "#proj-alpha", canonical: "#project", pattern: "glob:#proj-*"},
+		{tag: "#proj-a/b", canonical: "#proj-a/b"},
+		{tag: "#Insight", canonical: "#idea", pattern: "#insight"},
+		{tag: "#ideas", canonical: "#idea", pattern: "glob:#idea?"},
+		{tag: "#q3", canonical: "#quarter", pattern: "re:#(?:Q|q)[1-4]"},
+		{tag: "#Other", canonical: "#other"},
+		// A decomposed é is normalised to the composed form.
+		{tag: "#cafe\u0301", canonical: "#caf\u00e9"},
+	}
+
+	for _, testCase := range testCases {
+		canonical, rule := mapper.Explain(testCase.tag)
+		if canonical != testCase.canonical {
+			t.Errorf("%q: expected %q, got %q", testCase.tag, testCase.canonical, canonical)
+		}
+		pattern := ""
+		if rule != nil {
+			pattern = rule.Pattern
+		}
+		if pattern != testCase.pattern {
+			t.Errorf("%q: expected rule %q, got %q", testCase.tag, testCase.pattern, pattern)
+		}
+	}
+}
+
+func TestTagMapperPreservesCase(t *testing.T) {
+	mapper, err := utils.NewTagMapper(internal.AppConfig{TagMappings: map[string]string{"#ToDo": "#todo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canonical, ok := mapper.CanonicalTag("#todo"); ok || canonical != "#todo" {
+		t.Errorf("Expected #todo not to be mapped, got %q %v", canonical, ok)
+	}
+	if canonical, ok := mapper.CanonicalTag("#Idea"); ok || canonical != "#Idea" {
+		t.Errorf("Expected #Idea to keep its case, got %q", canonical)
+	}
+}
+
+func TestTagMapperErrors(t *testing.T) {
+	testCases := []struct {
+		app     internal.AppConfig
+		message string
+	}{
+		{
+			app:     internal.AppConfig{TagMappings: map[string]string{"re:#(": "#x"}},
+			message: "re:#(",
+		},
+		{
+			app:     internal.AppConfig{TagMappings: map[string]string{"glob:#[": "#x"}},
+			message: "glob:#[",
+		},
+		{
+			app: internal.AppConfig{
+				TagMappings: map[string]string{"#Idea": "#idea"},
+				TagSynonyms: map[string][]string{"#thought": {"#idea"}},
+				FoldCase:    true,
+			},
+			message: "already mapped",
+		},
+		{
+			app:     internal.AppConfig{UnicodeNormalization: "NFD"},
+			message: "unicode_normalization",
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := utils.NewTagMapper(testCase.app)
+		if err == nil || !strings.Contains(err.Error(), testCase.message) {
+			t.Errorf("Expected an error mentioning %q, got %v", testCase.message, err)
+		}
+	}
+}
+
+func TestParseConfigQuotedTagMappings(t *testing.T) {
+	config, err := utils.ParseConfig([]byte(`
+app:
+  tag_mappings:
+    "#to-do": "#todo"
+    #todo: #todo
+  tag_synonyms:
+    "#idea": ["#insight"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := utils.MapTagToCanonicalType("#to-do", *config); got != "#todo" {
+		t.Errorf("Expected the quoted key to map #to-do, got %q", got)
+	}
+	if got := utils.MapTagToCanonicalType("#insight", *config); got != "#idea" {
+		t.Errorf("Expected the synonym to map #insight, got %q", got)
+	}
+	if len(config.App.TagMappings) != 1 {
+		t.Errorf("Expected the unquoted key to be a comment, got %v", config.App.TagMappings)
+	}
+}
@@ -0,0 +1,54 @@
+package watcher
+
+import "time"
+
+// pendingEvent is an event waiting for its path to become quiet.
+type pendingEvent struct {
+	op    Op
+	timer *time.Timer
+	// generation identifies the timer that is allowed to deliver the event.
+	generation uint64
+}
+
+// dueEvent is sent by the timer of a pending event once the path is quiet.
+type dueEvent struct {
+	path       string
+	generation uint64
+}
+
+// queue coalesces the event with earlier events for the same path and (re)starts the
+// debounce timer of the path. The latest operation wins: a file that is removed and
+// created again during the window is reported as updated.
+func (w *Watcher) queue(event Event) {
+	if w.debounce <= 0 {
+		w.emit(event)
+		return
+	}
+
+	p, ok := w.pending[event.Path]
+	if ok {
+		p.timer.Stop()
+	}
+	w.generation++
+	p.op = event.Op
+	p.generation = w.generation
+	d := dueEvent{path: event.Path, generation: p.generation}
+	p.timer = time.AfterFunc(w.debounce, func() {
+		select {
+		case w.due <- d:
+		case <-w.quit:
+		}
+	})
+	w.pending[event.Path] = p
+}
+
+// flush delivers the pending event of a quiet path. Timers that were superseded by a
+// later event for the same path are ignored.
+func (w *Watcher) flush(d dueEvent) {
+	p, ok := w.pending[d.path]
+	if !ok || p.generation != d.generation {
+		return
+	}
+	delete(w.pending, d.path)
+	w.emit(Event{Path: d.path, Op: p.op})
+}
@@ -0,0 +1,306 @@
+/*
+Package watcher reports changes to the markdown files below a set of folders.
+
+It wraps fsnotify, which only watches single directories, and keeps the set of
+watched directories in sync as folders are created, removed or renamed.
+*/
+package watcher
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Op describes what happened to a path.
+type Op int
+
+const (
+	// Update means the markdown file was created or changed and should be parsed again.
+	Update Op = iota
+	// Remove means the path no longer exists. When the path was a directory, every
+	// file below it is gone as well.
+	Remove
+)
+
+func (op Op) String() string {
+	if op == Remove {
+		return "remove"
+	}
+	return "update"
+}
+
+// Event reports a change to a markdown file, or the removal of a directory.
+type Event struct {
+	Path string
+	Op   Op
+}
+
+// Watcher watches folders recursively for changes to markdown files.
+type Watcher struct {
+	fsw    *fsnotify.Watcher
+	events chan Event
+	errors chan error
+
+	// debounce is how long a path must be quiet before its event is delivered.
+	debounce time.Duration
+	pending  map[string]pendingEvent
+	due      chan dueEvent
+	// generation numbers the debounce timers so superseded ones can be told apart.
+	generation uint64
+
+	mu   sync.Mutex
+	dirs map[string]bool
+	// own maps paths to the hash of the content zettelo itself wrote to them.
+	own  map[string][sha256.Size]byte
+	quit chan struct{}
+	done chan struct{}
+}
+
+/*
+New starts watching the given folders and all of their subdirectories.
+
+Events for the same path are coalesced until the path has been quiet for the debounce
+window, so the burst of events an editor produces on save results in a single event.
+
+Usage:
+
+	w, err := watcher.New([]string{"/path/to/notes"}, 100*time.Millisecond)
+	for event := range w.Events() {
+		...
+	}
+
+Parameters:
+
+	roots ([]string): the folders to watch
+	debounce (time.Duration): the quiet period per path; 0 delivers every event immediately
+
+Returns:
+
+	(*Watcher): the watcher, which must be closed by the caller
+	(error): if the underlying file system watcher could not be created
+*/
+func New(roots []string, debounce time.Duration) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		fsw:      fsw,
+		events:   make(chan Event),
+		errors:   make(chan error),
+		debounce: debounce,
+		pending:  make(map[string]pendingEvent),
+		due:      make(chan dueEvent),
+		dirs:     make(map[string]bool),
+		own:      make(map[string][sha256.Size]byte),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, root := range roots {
+		if _, err := w.addTree(filepath.Clean(root)); err != nil {
+			fsw.Close()
+			return nil, err
+		}
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Events returns the channel of file changes.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Errors returns the channel of errors reported while watching.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Close stops watching. The event and error channels are closed afterwards.
+func (w *Watcher) Close() error {
+	close(w.quit)
+	err := w.fsw.Close()
+	<-w.done
+	return err
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	defer close(w.errors)
+	defer close(w.events)
+
+	for {
+		select {
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case d := <-w.due:
+			w.flush(d)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			w.fail(err)
+		}
+	}
+}
+
+func (w *Watcher) handle(event fsnotify.Event) {
+	path := filepath.Clean(event.Name)
+
+	switch {
+	case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+		// A renamed path is reported again by a create event under its new name.
+		w.removeTree(path)
+		w.queue(Event{Path: path, Op: Remove})
+
+	case event.Has(fsnotify.Create):
+		info, err := os.Stat(path)
+		if err != nil {
+			// The path was removed again before we could look at it.
+			return
+		}
+		if !info.IsDir() {
+			if isMarkdown(path) {
+				w.queue(Event{Path: path, Op: Update})
+			}
+			return
+		}
+		// Files may have been created in the new directory before it was watched.
+		files, err := w.addTree(path)
+		if err != nil {
+			w.fail(err)
+		}
+		for _, file := range files {
+			w.queue(Event{Path: file, Op: Update})
+		}
+
+	case event.Has(fsnotify.Write) || event.Has(fsnotify.Chmod):
+		// A permission change can make a note unreadable, or readable again.
+		if isMarkdown(path) {
+			w.queue(Event{Path: path, Op: Update})
+		}
+	}
+}
+
+/*
+IgnoreWrite records that zettelo itself is about to write content to path. The next update
+of path is not reported if the file still holds exactly that content, so zettelo's own
+writes are not echoed back as external changes. Later updates are reported even when they
+restore the same content.
+
+Usage:
+
+	w.IgnoreWrite(path, newContent)
+	err := utils.WriteFile(path, newContent, mode)
+
+Parameters:
+
+	path (string): the file that is written
+	content ([]byte): the content that is written
+*/
+func (w *Watcher) IgnoreWrite(path string, content []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.own[filepath.Clean(path)] = sha256.Sum256(content)
+}
+
+// isOwnWrite reports whether path holds the content zettelo wrote to it. Either way the
+// write is forgotten, so one write suppresses at most one event: a later edit restoring
+// the same content, such as an undo, is reported as usual.
+func (w *Watcher) isOwnWrite(path string) bool {
+	w.mu.Lock()
+	expected, ok := w.own[path]
+	delete(w.own, path)
+	w.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	content, err := ioutil.ReadFile(path)
+	return err == nil && sha256.Sum256(content) == expected
+}
+
+// emit delivers an event unless it echoes one of zettelo's own writes or the
+// watcher is being closed.
+func (w *Watcher) emit(event Event) {
+	if event.Op == Update && w.isOwnWrite(event.Path) {
+		return
+	}
+	if event.Op == Remove {
+		w.mu.Lock()
+		delete(w.own, event.Path)
+		w.mu.Unlock()
+	}
+	select {
+	case w.events <- event:
+	case <-w.quit:
+	}
+}
+
+// fail delivers an error unless the watcher is being closed.
+func (w *Watcher) fail(err error) {
+	select {
+	case w.errors <- err:
+	case <-w.quit:
+	}
+}
+
+// addTree watches root and its subdirectories and returns the markdown files found below it.
+func (w *Watcher) addTree(root string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := w.fsw.Add(path); err != nil {
+				return err
+			}
+			w.mu.Lock()
+			w.dirs[path] = true
+			w.mu.Unlock()
+		} else if isMarkdown(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// removeTree stops watching path and every directory below it.
+func (w *Watcher) removeTree(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	prefix := path + string(filepath.Separator)
+	for dir := range w.dirs {
+		if dir == path || strings.HasPrefix(dir, prefix) {
+			// Removed directories are no longer watched by the kernel; ignore the error.
+			w.fsw.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+}
+
+// WatchedDirs returns the number of directories being watched.
+func (w *Watcher) WatchedDirs() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.dirs)
+}
+
+func isMarkdown(path string) bool {
+	return filepath.Ext(path) == ".md"
+}
@@ -0,0 +1,190 @@
+package watcher_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ozcankasal/zettelo/internal/watcher"
+)
+
+// expectEvent reads events until one matches path and op.
+func expectEvent(t *testing.T, w *watcher.Watcher, path string, op watcher.Op) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-w.Events():
+			if event.Path == path && event.Op == op {
+				return
+			}
+		case err := <-w.Errors():
+			t.Fatalf("Unexpected error: %v", err)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %v of %s", op, path)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherEvents(t *testing.T) {
+	root := t.TempDir()
+	w, err := watcher.New([]string{root}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	note := filepath.Join(root, "note.md")
+	writeFile(t, note, "#todo one")
+	expectEvent(t, w, note, watcher.Update)
+
+	// Files in new directories are reported and the directories are watched.
+	dir := filepath.Join(root, "sub", "deeper")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(dir, "nested.md")
+	writeFile(t, nested, "#idea")
+	expectEvent(t, w, nested, watcher.Update)
+	writeFile(t, nested, "#idea changed")
+	expectEvent(t, w, nested, watcher.Update)
+
+	renamed := filepath.Join(root, "renamed.md")
+	if err := os.Rename(note, renamed); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, w, note, watcher.Remove)
+	expectEvent(t, w, renamed, watcher.Update)
+
+	if err := os.Remove(renamed); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, w, renamed, watcher.Remove)
+
+	sub := filepath.Join(root, "sub")
+	if err := os.RemoveAll(sub); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, w, sub, watcher.Remove)
+	if dirs := w.WatchedDirs(); dirs != 1 {
+		t.Errorf("Expected only the root to be watched, got %d directories", dirs)
+	}
+}
+
+func TestCloseWithPendingEvents(t *testing.T) {
+	root := t.TempDir()
+	w, err := watcher.New([]string{root}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "note.md"), "#todo")
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		w.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on an undelivered event")
+	}
+}
+
+// collectEvents returns the events received until nothing happens for quiet.
+func collectEvents(w *watcher.Watcher, quiet time.Duration) []watcher.Event {
+	var events []watcher.Event
+	for {
+		select {
+		case event := <-w.Events():
+			events = append(events, event)
+		case <-time.After(quiet):
+			return events
+		}
+	}
+}
+
+func TestDebounceCoalescesEvents(t *testing.T) {
+	root := t.TempDir()
+	w, err := watcher.New([]string{root}, 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Simulate an editor saving through a temporary file and a rename.
+	note := filepath.Join(root, "note.md")
+	writeFile(t, note, "#todo one")
+	for i := 0; i < 5; i++ {
+		writeFile(t, note+".tmp", "#todo two")
+		if err := os.Rename(note+".tmp", note); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events := collectEvents(w, time.Second)
+	var noteEvents []watcher.Event
+	for _, event := range events {
+		if event.Path == note {
+			noteEvents = append(noteEvents, event)
+		}
+	}
+	if len(noteEvents) != 1 || noteEvents[0].Op != watcher.Update {
+		t.Errorf("Expected a single update of %s, got %v", note, noteEvents)
+	}
+}
+
+func TestOwnWritesAreIgnored(t *testing.T) {
+	root := t.TempDir()
+	note := filepath.Join(root, "note.md")
+	writeFile(t, note, "#todo")
+
+	w, err := watcher.New([]string{root}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	content := "---\nid: 1\n---\n#todo"
+	w.IgnoreWrite(note, []byte(content))
+	writeFile(t, note, content)
+	if events := collectEvents(w, 500*time.Millisecond); len(events) != 0 {
+		t.Errorf("Expected our own write to be ignored, got %v", events)
+	}
+
+	writeFile(t, note, content+" changed")
+	expectEvent(t, w, note, watcher.Update)
+}
+
+func TestOwnWriteIsIgnoredOnce(t *testing.T) {
+	root := t.TempDir()
+	note := filepath.Join(root, "note.md")
+	writeFile(t, note, "#todo")
+
+	w, err := watcher.New([]string{root}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	content := "---\nid: 1\n---\n#todo"
+	w.IgnoreWrite(note, []byte(content))
+	writeFile(t, note, content)
+	if events := collectEvents(w, 500*time.Millisecond); len(events) != 0 {
+		t.Errorf("Expected our own write to be ignored, got %v", events)
+	}
+
+	// Writing the same content again, like an undo or a checkout would, is reported.
+	writeFile(t, note, content)
+	expectEvent(t, w, note, watcher.Update)
+}
@@ -0,0 +1,44 @@
+// connectIndex calls onIndex with every index the server pushes over the websocket.
+function connectIndex(onIndex, status) {
+  connectLive("/hashtags", onIndex, status);
+}
+
+// connectVersions calls onVersion with the version of every index, without the index.
+function connectVersions(onVersion, status) {
+  connectLive("/versions", message => onVersion(message.version), status);
+}
+
+// connectLive calls onMessage with every message the server pushes over the websocket at
+// path. When the connection drops, for a server restart or because the client fell behind,
+// it shows the status element and reconnects with a growing delay. The server sends the
+// current message first on every connection, so no change made while disconnected is missed.
+function connectLive(path, onMessage, status) {
+  const url = (location.protocol === "https:" ? "wss://" : "ws://") + location.host + path;
+  const minDelay = 1000, maxDelay = 30000;
+  let delay = minDelay;
+
+  function setStatus(text) {
+    if (status) {
+      status.textContent = text;
+      status.classList.toggle("d-none", text === "");
+    }
+  }
+
+  function connect() {
+    const socket = new WebSocket(url);
+    socket.onopen = function() {
+      delay = minDelay;
+      setStatus("");
+    };
+    socket.onmessage = function(event) {
+      onMessage(JSON.parse(event.data));
+    };
+    socket.onclose = function() {
+      setStatus("Disconnected, reconnecting in " + Math.round(delay / 1000) + "s");
+      setTimeout(connect, delay);
+      delay = Math.min(delay * 2, maxDelay);
+    };
+  }
+
+  connect();
+}